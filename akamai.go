@@ -0,0 +1,180 @@
+package tls_client
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bogdanfinn/fhttp/http2"
+)
+
+// akamaiPseudoHeaders maps the letters of the pseudo header order of an akamai fingerprint to the pseudo headers.
+var akamaiPseudoHeaders = map[string]string{
+	"m": ":method",
+	"a": ":authority",
+	"s": ":scheme",
+	"p": ":path",
+}
+
+// ParseAkamaiFingerprint returns the http2 settings, the settings order, the pseudo header order, the connection flow
+// and the priority frames of an akamai fingerprint like "1:65536,2:0,3:1000,4:6291456,6:262144|15663105|0|m,a,s,p"
+// in the order NewClientProfile takes them.
+func ParseAkamaiFingerprint(fingerprint string) (map[http2.SettingID]uint32, []http2.SettingID, []string, uint32, []http2.Priority, error) {
+	parts := strings.Split(strings.TrimSpace(fingerprint), "|")
+	if len(parts) != 4 {
+		return nil, nil, nil, 0, nil, fmt.Errorf("akamai fingerprint %q does not consist of 4 parts", fingerprint)
+	}
+
+	settings := make(map[http2.SettingID]uint32)
+	var settingsOrder []http2.SettingID
+
+	for _, setting := range splitAkamaiList(parts[0]) {
+		id, value, ok := strings.Cut(setting, ":")
+		if !ok {
+			return nil, nil, nil, 0, nil, fmt.Errorf("invalid setting %q", setting)
+		}
+
+		settingId, err := strconv.ParseUint(id, 10, 16)
+		if err != nil {
+			return nil, nil, nil, 0, nil, fmt.Errorf("invalid setting id %q", id)
+		}
+
+		settingValue, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, nil, nil, 0, nil, fmt.Errorf("invalid value of setting %s: %q", id, value)
+		}
+
+		if _, ok := settings[http2.SettingID(settingId)]; ok {
+			return nil, nil, nil, 0, nil, fmt.Errorf("setting %s is repeated", id)
+		}
+
+		settings[http2.SettingID(settingId)] = uint32(settingValue)
+		settingsOrder = append(settingsOrder, http2.SettingID(settingId))
+	}
+
+	// "00" stands for a missing WINDOW_UPDATE frame
+	connectionFlow, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, nil, nil, 0, nil, fmt.Errorf("invalid connection flow %q", parts[1])
+	}
+
+	var priorities []http2.Priority
+	if parts[2] != "0" {
+		for _, priority := range splitAkamaiList(parts[2]) {
+			priorityFrame, err := parseAkamaiPriority(priority)
+			if err != nil {
+				return nil, nil, nil, 0, nil, err
+			}
+
+			priorities = append(priorities, priorityFrame)
+		}
+	}
+
+	var pseudoHeaderOrder []string
+	for _, letter := range splitAkamaiList(parts[3]) {
+		pseudoHeader, ok := akamaiPseudoHeaders[letter]
+		if !ok {
+			return nil, nil, nil, 0, nil, fmt.Errorf("invalid pseudo header %q", letter)
+		}
+
+		pseudoHeaderOrder = append(pseudoHeaderOrder, pseudoHeader)
+	}
+
+	return settings, settingsOrder, pseudoHeaderOrder, uint32(connectionFlow), priorities, nil
+}
+
+// parseAkamaiPriority parses a priority frame written as stream id:exclusive:dependency:weight.
+func parseAkamaiPriority(priority string) (http2.Priority, error) {
+	fields := strings.Split(priority, ":")
+	if len(fields) != 4 {
+		return http2.Priority{}, fmt.Errorf("invalid priority frame %q", priority)
+	}
+
+	streamId, err := strconv.ParseUint(fields[0], 10, 31)
+	if err != nil {
+		return http2.Priority{}, fmt.Errorf("invalid stream id of priority frame %q", priority)
+	}
+
+	if fields[1] != "0" && fields[1] != "1" {
+		return http2.Priority{}, fmt.Errorf("invalid exclusive flag of priority frame %q", priority)
+	}
+
+	streamDep, err := strconv.ParseUint(fields[2], 10, 31)
+	if err != nil {
+		return http2.Priority{}, fmt.Errorf("invalid stream dependency of priority frame %q", priority)
+	}
+
+	// the fingerprint contains the weight, the frame the weight minus one
+	weight, err := strconv.ParseUint(fields[3], 10, 16)
+	if err != nil || weight < 1 || weight > 256 {
+		return http2.Priority{}, fmt.Errorf("invalid weight of priority frame %q", priority)
+	}
+
+	return http2.Priority{
+		StreamID: uint32(streamId),
+		PriorityParam: http2.PriorityParam{
+			StreamDep: uint32(streamDep),
+			Exclusive: fields[1] == "1",
+			Weight:    uint8(weight - 1),
+		},
+	}, nil
+}
+
+func splitAkamaiList(list string) []string {
+	if list == "" {
+		return nil
+	}
+
+	return strings.Split(list, ",")
+}
+
+// AkamaiFingerprint returns the akamai fingerprint of the http2 connection preface and request headers the profile sends.
+func (c ClientProfile) AkamaiFingerprint() string {
+	settings, settingsOrder := c.settings, c.settingsOrder
+	if settings == nil {
+		settings, settingsOrder = DefaultH2Settings.copy()
+	}
+
+	// the transport sends the settings of the order only
+	var settingParts []string
+	for _, id := range settingsOrder {
+		settingParts = append(settingParts, fmt.Sprintf("%d:%d", id, settings[id]))
+	}
+
+	priorityParts := []string{"0"}
+	if len(c.priorities) > 0 {
+		priorityParts = nil
+	}
+
+	for _, priority := range c.priorities {
+		exclusive := 0
+		if priority.PriorityParam.Exclusive {
+			exclusive = 1
+		}
+
+		priorityParts = append(priorityParts, fmt.Sprintf("%d:%d:%d:%d", priority.StreamID, exclusive, priority.PriorityParam.StreamDep, int(priority.PriorityParam.Weight)+1))
+	}
+
+	var pseudoHeaderParts []string
+	for _, pseudoHeader := range c.pseudoHeaderOrder {
+		if name := strings.TrimPrefix(pseudoHeader, ":"); name != "" {
+			pseudoHeaderParts = append(pseudoHeaderParts, name[:1])
+		}
+	}
+
+	return strings.Join([]string{
+		strings.Join(settingParts, ","),
+		strconv.FormatUint(uint64(c.connectionFlow), 10),
+		strings.Join(priorityParts, ","),
+		strings.Join(pseudoHeaderParts, ","),
+	}, "|")
+}
+
+// AkamaiFingerprintHash returns the md5 hash of the akamai fingerprint of the profile.
+func (c ClientProfile) AkamaiFingerprintHash() string {
+	hash := md5.Sum([]byte(c.AkamaiFingerprint()))
+
+	return hex.EncodeToString(hash[:])
+}
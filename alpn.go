@@ -0,0 +1,118 @@
+package tls_client
+
+import (
+	"fmt"
+
+	tls "github.com/bogdanfinn/utls"
+)
+
+const alpnHttp1 = "http/1.1"
+
+// clientHelloIdWithALPN returns a ClientHelloID which sends the spec of the given one, but offers only the given
+// application layer protocols. Application settings (ALPS) are kept for the protocols which are still offered.
+func clientHelloIdWithALPN(clientHelloId tls.ClientHelloID, protocols []string) (tls.ClientHelloID, error) {
+	// the spec is generated once to fail early, the factory creates fresh extensions for every handshake
+	if _, err := clientHelloSpec(clientHelloId); err != nil {
+		return tls.ClientHelloID{}, fmt.Errorf("failed to rewrite alpn of client hello %s: %w", clientHelloId.Str(), err)
+	}
+
+	return tls.ClientHelloID{
+		// a client name unknown to utls makes it use the spec factory instead of its built-in spec
+		Client:               clientHelloId.Client + "-alpn",
+		RandomExtensionOrder: clientHelloId.RandomExtensionOrder,
+		Version:              clientHelloId.Version,
+		Seed:                 clientHelloId.Seed,
+		SpecFactory: func() (tls.ClientHelloSpec, error) {
+			spec, err := clientHelloSpec(clientHelloId)
+			if err != nil {
+				return tls.ClientHelloSpec{}, err
+			}
+
+			return rewriteALPN(spec, protocols), nil
+		},
+	}, nil
+}
+
+func clientHelloSpec(clientHelloId tls.ClientHelloID) (tls.ClientHelloSpec, error) {
+	spec, err := tls.UTLSIdToSpec(clientHelloId)
+	if err == nil {
+		return spec, nil
+	}
+
+	return clientHelloId.ToSpec()
+}
+
+func rewriteALPN(spec tls.ClientHelloSpec, protocols []string) tls.ClientHelloSpec {
+	extensions := make([]tls.TLSExtension, 0, len(spec.Extensions)+1)
+	hasALPN := false
+
+	for _, extension := range spec.Extensions {
+		switch ext := extension.(type) {
+		case *tls.ALPNExtension:
+			hasALPN = true
+			extension = &tls.ALPNExtension{AlpnProtocols: append([]string(nil), protocols...)}
+		case *tls.ApplicationSettingsExtension:
+			var supported []string
+			for _, protocol := range ext.SupportedProtocols {
+				if containsString(protocols, protocol) {
+					supported = append(supported, protocol)
+				}
+			}
+
+			if len(supported) == 0 {
+				continue
+			}
+
+			extension = &tls.ApplicationSettingsExtension{SupportedProtocols: supported}
+		}
+
+		extensions = append(extensions, extension)
+	}
+
+	if !hasALPN && len(protocols) > 0 {
+		alpn := &tls.ALPNExtension{AlpnProtocols: append([]string(nil), protocols...)}
+
+		// the padding extension has to stay the last one
+		n := len(extensions)
+		if n > 0 {
+			if padding, ok := extensions[n-1].(*tls.UtlsPaddingExtension); ok {
+				extensions = append(extensions[:n-1], alpn, padding)
+			} else {
+				extensions = append(extensions, alpn)
+			}
+		} else {
+			extensions = append(extensions, alpn)
+		}
+	}
+
+	spec.Extensions = extensions
+
+	return spec
+}
+
+// checkNegotiatedProtocol fails if the server selected a protocol which was not offered, or h2 although http/1.1 is forced.
+func checkNegotiatedProtocol(negotiated string, offered []string, forceHttp1 bool) error {
+	if negotiated == "" {
+		return nil
+	}
+
+	if forceHttp1 && negotiated != alpnHttp1 {
+		return fmt.Errorf("%w: server selected %q although http/1.1 is forced", ErrUnexpectedProtocol, negotiated)
+	}
+
+	if offered != nil && !containsString(offered, negotiated) {
+		return fmt.Errorf("%w: server selected %q which is not one of %q", ErrUnexpectedProtocol, negotiated, offered)
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
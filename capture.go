@@ -0,0 +1,396 @@
+package tls_client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+)
+
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+	linkTypeLinuxSL2 = 276
+)
+
+// CapturedProfile is the profile of the client of one tcp stream of a capture.
+type CapturedProfile struct {
+	// Client and Server are the addresses of the stream, e.g. "192.168.178.20:51234".
+	Client string
+	Server string
+	// ServerName is the server name the ClientHello asked for, empty if it had none.
+	ServerName string
+	// Http2 reports whether the http2 preface of the client could be decrypted, without it the http2 fields of the profile are empty.
+	Http2   bool
+	Profile ClientProfile
+	// Err is set if the ClientHello of the stream could not be parsed, e.g. because the capture lacks a part of it.
+	// The other fields except for Client and Server are empty then.
+	Err error
+}
+
+// ProfilesFromCapture returns a profile for every tcp stream of a pcap or pcapng capture which starts with a ClientHello.
+// A stream whose ClientHello cannot be parsed does not fail the import, its CapturedProfile has the error in Err.
+// With the contents of a key log file (SSLKEYLOGFILE) the http2 settings, the connection flow, the priority frames
+// and the pseudo header order are taken from the decrypted http2 preface of the client, nil skips the decryption.
+func ProfilesFromCapture(capture []byte, keyLog []byte) ([]CapturedProfile, error) {
+	packets, err := readCapture(capture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture: %w", err)
+	}
+
+	keys, err := parseKeyLog(keyLog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key log: %w", err)
+	}
+
+	var profiles []CapturedProfile
+	for _, stream := range reassembleStreams(packets) {
+		client, server := stream.up.assemble(), stream.down.assemble()
+
+		// the side which sends the ClientHello is the client
+		if !startsWithClientHello(client) {
+			if !startsWithClientHello(server) {
+				continue
+			}
+
+			client, server = server, client
+			stream.up, stream.down = stream.down, stream.up
+		}
+
+		captured, err := profileFromStream(client, server, keys)
+		if err != nil {
+			captured = CapturedProfile{Err: fmt.Errorf("stream %s -> %s: %w", stream.up.src, stream.up.dst, err)}
+		}
+
+		captured.Client = stream.up.src
+		captured.Server = stream.up.dst
+
+		profiles = append(profiles, captured)
+	}
+
+	return profiles, nil
+}
+
+func startsWithClientHello(data []byte) bool {
+	return len(data) > 5 && data[0] == recordTypeHandshake && data[5] == handshakeTypeHello
+}
+
+// tcpPacket is the tcp segment of a captured packet.
+type tcpPacket struct {
+	src, dst string
+	seq      uint32
+	syn      bool
+	payload  []byte
+}
+
+// readCapture returns the tcp segments of a pcap or pcapng capture in capture order.
+func readCapture(capture []byte) ([]tcpPacket, error) {
+	if len(capture) < 4 {
+		return nil, errors.New("too short")
+	}
+
+	switch {
+	case bytes.Equal(capture[:4], []byte{0x0a, 0x0d, 0x0d, 0x0a}):
+		return readPcapng(capture)
+	case bytes.Equal(capture[:4], []byte{0xd4, 0xc3, 0xb2, 0xa1}), bytes.Equal(capture[:4], []byte{0x4d, 0x3c, 0xb2, 0xa1}):
+		return readPcap(capture, binary.LittleEndian)
+	case bytes.Equal(capture[:4], []byte{0xa1, 0xb2, 0xc3, 0xd4}), bytes.Equal(capture[:4], []byte{0xa1, 0xb2, 0x3c, 0x4d}):
+		return readPcap(capture, binary.BigEndian)
+	default:
+		return nil, errors.New("neither pcap nor pcapng")
+	}
+}
+
+func readPcap(capture []byte, order binary.ByteOrder) ([]tcpPacket, error) {
+	if len(capture) < 24 {
+		return nil, errors.New("truncated pcap header")
+	}
+
+	linkType := order.Uint32(capture[20:24]) & 0xffff
+
+	var packets []tcpPacket
+	for data := capture[24:]; len(data) >= 16; {
+		length := int(order.Uint32(data[8:12]))
+		if len(data) < 16+length {
+			// the capture was cut off while the last record was written
+			break
+		}
+
+		if packet, ok := parseLinkLayer(linkType, data[16:16+length]); ok {
+			packets = append(packets, packet)
+		}
+
+		data = data[16+length:]
+	}
+
+	return packets, nil
+}
+
+func readPcapng(capture []byte) ([]tcpPacket, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	var linkTypes []uint32
+	var packets []tcpPacket
+
+	for data := capture; len(data) >= 12; {
+		blockType := order.Uint32(data[:4])
+
+		if blockType == 0x0a0d0d0a {
+			// every section header sets the byte order of its section
+			switch {
+			case bytes.Equal(data[8:12], []byte{0x4d, 0x3c, 0x2b, 0x1a}):
+				order = binary.LittleEndian
+			case bytes.Equal(data[8:12], []byte{0x1a, 0x2b, 0x3c, 0x4d}):
+				order = binary.BigEndian
+			default:
+				return nil, errors.New("invalid pcapng section header")
+			}
+
+			linkTypes = nil
+		}
+
+		length := int(order.Uint32(data[4:8]))
+		if length < 12 {
+			return nil, errors.New("invalid pcapng block")
+		}
+
+		if length > len(data) {
+			// the capture was cut off while the last block was written
+			break
+		}
+
+		body := data[8 : length-4]
+
+		switch blockType {
+		case 1:
+			// interface description
+			if len(body) >= 2 {
+				linkTypes = append(linkTypes, uint32(order.Uint16(body[:2])))
+			}
+		case 6:
+			// enhanced packet
+			if len(body) >= 20 {
+				iface, captured := order.Uint32(body[:4]), int(order.Uint32(body[12:16]))
+				if int(iface) < len(linkTypes) && 20+captured <= len(body) {
+					if packet, ok := parseLinkLayer(linkTypes[iface], body[20:20+captured]); ok {
+						packets = append(packets, packet)
+					}
+				}
+			}
+		case 3:
+			// simple packet, always of the first interface
+			if len(body) >= 4 && len(linkTypes) > 0 {
+				if packet, ok := parseLinkLayer(linkTypes[0], body[4:]); ok {
+					packets = append(packets, packet)
+				}
+			}
+		}
+
+		data = data[length:]
+	}
+
+	return packets, nil
+}
+
+// parseLinkLayer returns the tcp segment of a packet, false if it is none.
+func parseLinkLayer(linkType uint32, frame []byte) (tcpPacket, bool) {
+	var etherType uint16
+
+	switch linkType {
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return tcpPacket{}, false
+		}
+
+		etherType, frame = binary.BigEndian.Uint16(frame[12:14]), frame[14:]
+		// 802.1Q and 802.1ad tags
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(frame) >= 4 {
+			etherType, frame = binary.BigEndian.Uint16(frame[2:4]), frame[4:]
+		}
+	case linkTypeNull:
+		if len(frame) < 4 {
+			return tcpPacket{}, false
+		}
+
+		// the address family is in the byte order of the capturing host
+		family := binary.LittleEndian.Uint32(frame[:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(frame[:4])
+		}
+
+		etherType, frame = 0x86dd, frame[4:]
+		if family == 2 {
+			etherType = 0x0800
+		}
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return tcpPacket{}, false
+		}
+
+		etherType, frame = binary.BigEndian.Uint16(frame[14:16]), frame[16:]
+	case linkTypeLinuxSL2:
+		if len(frame) < 20 {
+			return tcpPacket{}, false
+		}
+
+		etherType, frame = binary.BigEndian.Uint16(frame[:2]), frame[20:]
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		if len(frame) < 1 {
+			return tcpPacket{}, false
+		}
+
+		etherType = 0x86dd
+		if frame[0]>>4 == 4 {
+			etherType = 0x0800
+		}
+	default:
+		return tcpPacket{}, false
+	}
+
+	var src, dst net.IP
+	var segment []byte
+
+	switch etherType {
+	case 0x0800:
+		if len(frame) < 20 || frame[9] != 6 {
+			return tcpPacket{}, false
+		}
+
+		headerLength, totalLength := int(frame[0]&0x0f)*4, int(binary.BigEndian.Uint16(frame[2:4]))
+		// fragments are not reassembled
+		if binary.BigEndian.Uint16(frame[6:8])&0x3fff != 0 || headerLength < 20 || totalLength < headerLength || totalLength > len(frame) {
+			return tcpPacket{}, false
+		}
+
+		src, dst, segment = net.IP(frame[12:16]), net.IP(frame[16:20]), frame[headerLength:totalLength]
+	case 0x86dd:
+		if len(frame) < 40 || frame[6] != 6 {
+			return tcpPacket{}, false
+		}
+
+		payloadLength := int(binary.BigEndian.Uint16(frame[4:6]))
+		if 40+payloadLength > len(frame) {
+			return tcpPacket{}, false
+		}
+
+		src, dst, segment = net.IP(frame[8:24]), net.IP(frame[24:40]), frame[40:40+payloadLength]
+	default:
+		return tcpPacket{}, false
+	}
+
+	if len(segment) < 20 {
+		return tcpPacket{}, false
+	}
+
+	dataOffset := int(segment[12]>>4) * 4
+	if dataOffset < 20 || dataOffset > len(segment) {
+		return tcpPacket{}, false
+	}
+
+	return tcpPacket{
+		src:     net.JoinHostPort(src.String(), strconv.Itoa(int(binary.BigEndian.Uint16(segment[0:2])))),
+		dst:     net.JoinHostPort(dst.String(), strconv.Itoa(int(binary.BigEndian.Uint16(segment[2:4])))),
+		seq:     binary.BigEndian.Uint32(segment[4:8]),
+		syn:     segment[13]&0x02 != 0,
+		payload: segment[dataOffset:],
+	}, true
+}
+
+// tcpStream holds both directions of a tcp connection, up is the direction of the first packet.
+type tcpStream struct {
+	up, down *tcpDirection
+}
+
+type tcpDirection struct {
+	src, dst string
+	// isn is the sequence number of the SYN, valid if hasSyn
+	isn      uint32
+	hasSyn   bool
+	segments []tcpPacket
+}
+
+// reassembleStreams groups the segments by connection in the order the connections appear in the capture.
+func reassembleStreams(packets []tcpPacket) []*tcpStream {
+	var streams []*tcpStream
+	open := make(map[string]*tcpStream)
+
+	for _, packet := range packets {
+		key := packet.src + "|" + packet.dst
+		if packet.dst < packet.src {
+			key = packet.dst + "|" + packet.src
+		}
+
+		stream, ok := open[key]
+
+		direction := (*tcpDirection)(nil)
+		if ok {
+			direction = stream.up
+			if packet.src != stream.up.src {
+				direction = stream.down
+			}
+		}
+
+		// a SYN with another sequence number on the same addresses starts a new connection
+		if !ok || (packet.syn && direction.hasSyn && packet.seq != direction.isn) {
+			stream = &tcpStream{
+				up:   &tcpDirection{src: packet.src, dst: packet.dst},
+				down: &tcpDirection{src: packet.dst, dst: packet.src},
+			}
+			open[key] = stream
+			streams = append(streams, stream)
+			direction = stream.up
+		}
+
+		if packet.syn && !direction.hasSyn {
+			direction.isn, direction.hasSyn = packet.seq, true
+		}
+
+		if len(packet.payload) > 0 {
+			direction.segments = append(direction.segments, packet)
+		}
+	}
+
+	return streams
+}
+
+// assemble returns the payload in sequence order up to the first gap, retransmitted data is taken once.
+func (d *tcpDirection) assemble() []byte {
+	if len(d.segments) == 0 {
+		return nil
+	}
+
+	base := d.isn + 1
+	if !d.hasSyn {
+		base = d.segments[0].seq
+		for _, segment := range d.segments {
+			if int32(segment.seq-base) < 0 {
+				base = segment.seq
+			}
+		}
+	}
+
+	segments := append([]tcpPacket(nil), d.segments...)
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].seq-base < segments[j].seq-base
+	})
+
+	var data []byte
+	for _, segment := range segments {
+		offset := int(segment.seq - base)
+		if offset > len(data) {
+			break
+		}
+
+		if end := offset + len(segment.payload); end > len(data) {
+			data = append(data, segment.payload[len(data)-offset:]...)
+		}
+	}
+
+	return data
+}
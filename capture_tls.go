@@ -0,0 +1,546 @@
+package tls_client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/bogdanfinn/fhttp/http2/hpack"
+	tls "github.com/bogdanfinn/utls"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	recordTypeChangeCipherSpec = 20
+	recordTypeApplicationData  = 23
+	handshakeTypeServerHello   = 2
+	handshakeTypeFinished      = 20
+)
+
+// keyLog holds the secrets of a key log file by label and client random.
+type keyLog map[string]map[string][]byte
+
+func parseKeyLog(data []byte) (keyLog, error) {
+	keys := make(keyLog)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+
+		secret, err := hex.DecodeString(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret of %s: %w", fields[0], err)
+		}
+
+		if keys[fields[0]] == nil {
+			keys[fields[0]] = make(map[string][]byte)
+		}
+
+		keys[fields[0]][strings.ToLower(fields[1])] = secret
+	}
+
+	return keys, scanner.Err()
+}
+
+func (k keyLog) secret(label string, clientRandom []byte) []byte {
+	return k[label][hex.EncodeToString(clientRandom)]
+}
+
+// captureSuite describes the record protection of the AEAD cipher suites browsers offer.
+type captureSuite struct {
+	keyLength int
+	hash      func() hash.Hash
+	chacha    bool
+}
+
+var captureSuites = map[uint16]captureSuite{
+	tls.TLS_AES_128_GCM_SHA256:                        {16, sha256.New, false},
+	tls.TLS_AES_256_GCM_SHA384:                        {32, sha512.New384, false},
+	tls.TLS_CHACHA20_POLY1305_SHA256:                  {32, sha256.New, true},
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256:       {16, sha256.New, false},
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:         {16, sha256.New, false},
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256:               {16, sha256.New, false},
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384:       {32, sha512.New384, false},
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:         {32, sha512.New384, false},
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384:               {32, sha512.New384, false},
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256: {32, sha256.New, true},
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256:   {32, sha256.New, true},
+}
+
+type tlsRecord struct {
+	header  []byte
+	payload []byte
+}
+
+// splitRecords returns the complete records of the data.
+func splitRecords(data []byte) []tlsRecord {
+	var records []tlsRecord
+	for len(data) >= 5 {
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+length {
+			break
+		}
+
+		records = append(records, tlsRecord{header: data[:5], payload: data[5 : 5+length]})
+		data = data[5+length:]
+	}
+
+	return records
+}
+
+// profileFromStream returns the profile of the client of a tcp stream, the http2 fields are filled if the client data can be decrypted.
+func profileFromStream(client []byte, server []byte, keys keyLog) (CapturedProfile, error) {
+	message, err := clientHelloMessage(client)
+	if err != nil {
+		return CapturedProfile{}, err
+	}
+
+	profile, err := ProfileFromClientHello(message)
+	if err != nil {
+		return CapturedProfile{}, err
+	}
+
+	clientRandom, serverName := clientHelloFields(message)
+	captured := CapturedProfile{ServerName: serverName, Profile: profile}
+
+	plaintext, ok := decryptClientData(client, server, clientRandom, keys)
+	if !ok {
+		return captured, nil
+	}
+
+	preface, ok := parseH2Preface(plaintext)
+	if !ok {
+		return captured, nil
+	}
+
+	captured.Http2 = true
+	captured.Profile = ProfileFrom(profile).
+		WithSettings(preface.settings, preface.settingsOrder).
+		WithConnectionFlow(preface.connectionFlow).
+		WithPriorities(preface.priorities).
+		WithPseudoHeaderOrder(preface.pseudoHeaderOrder).
+		Build()
+
+	return captured, nil
+}
+
+// clientHelloFields returns the client random and the server name of a ClientHello handshake message.
+func clientHelloFields(message []byte) ([]byte, string) {
+	s := cryptobyte.String(message)
+
+	var random, sessionId, cipherSuites, compressionMethods, extensions cryptobyte.String
+	if !s.Skip(4+2) || !s.ReadBytes((*[]byte)(&random), 32) || !s.ReadUint8LengthPrefixed(&sessionId) ||
+		!s.ReadUint16LengthPrefixed(&cipherSuites) || !s.ReadUint8LengthPrefixed(&compressionMethods) ||
+		!s.ReadUint16LengthPrefixed(&extensions) {
+		return random, ""
+	}
+
+	for !extensions.Empty() {
+		var id uint16
+		var data, names cryptobyte.String
+		if !extensions.ReadUint16(&id) || !extensions.ReadUint16LengthPrefixed(&data) {
+			break
+		}
+
+		if id != tls.ExtensionServerName || !data.ReadUint16LengthPrefixed(&names) {
+			continue
+		}
+
+		var nameType uint8
+		var name cryptobyte.String
+		if names.ReadUint8(&nameType) && nameType == 0 && names.ReadUint16LengthPrefixed(&name) {
+			return random, string(name)
+		}
+	}
+
+	return random, ""
+}
+
+// decryptClientData returns the application data the client sent, false if it cannot be decrypted.
+func decryptClientData(client []byte, server []byte, clientRandom []byte, keys keyLog) ([]byte, bool) {
+	suite, serverRandom, tls13, ok := parseServerHello(server)
+	if !ok {
+		return nil, false
+	}
+
+	params, ok := captureSuites[suite]
+	if !ok {
+		return nil, false
+	}
+
+	records := splitRecords(client)
+
+	if tls13 {
+		return decryptTLS13(records, params, keys.secret("CLIENT_HANDSHAKE_TRAFFIC_SECRET", clientRandom), keys.secret("CLIENT_TRAFFIC_SECRET_0", clientRandom))
+	}
+
+	masterSecret := keys.secret("CLIENT_RANDOM", clientRandom)
+	if masterSecret == nil {
+		return nil, false
+	}
+
+	return decryptTLS12(records, params, masterSecret, clientRandom, serverRandom)
+}
+
+// parseServerHello returns the cipher suite and the random of the ServerHello and whether it selected tls 1.3.
+func parseServerHello(server []byte) (uint16, []byte, bool, bool) {
+	var message []byte
+	for _, record := range splitRecords(server) {
+		if record.header[0] != recordTypeHandshake {
+			break
+		}
+
+		message = append(message, record.payload...)
+		if len(message) >= 4 && len(message) >= 4+(int(message[1])<<16|int(message[2])<<8|int(message[3])) {
+			break
+		}
+	}
+
+	s := cryptobyte.String(message)
+
+	var messageType uint8
+	var body, random, sessionId, extensions cryptobyte.String
+	var suite uint16
+	if !s.ReadUint8(&messageType) || messageType != handshakeTypeServerHello || !s.ReadUint24LengthPrefixed(&body) ||
+		!body.Skip(2) || !body.ReadBytes((*[]byte)(&random), 32) || !body.ReadUint8LengthPrefixed(&sessionId) ||
+		!body.ReadUint16(&suite) || !body.Skip(1) {
+		return 0, nil, false, false
+	}
+
+	if !body.ReadUint16LengthPrefixed(&extensions) {
+		return suite, random, false, true
+	}
+
+	for !extensions.Empty() {
+		var id, version uint16
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&id) || !extensions.ReadUint16LengthPrefixed(&data) {
+			break
+		}
+
+		if id == tls.ExtensionSupportedVersions && data.ReadUint16(&version) {
+			return suite, random, version == tls.VersionTLS13, true
+		}
+	}
+
+	return suite, random, false, true
+}
+
+// recordDecrypter opens the protected records of one direction.
+type recordDecrypter struct {
+	aead          cipher.AEAD
+	iv            []byte
+	seq           uint64
+	tls13         bool
+	explicitNonce bool
+}
+
+func newRecordDecrypter(params captureSuite, key []byte, iv []byte, tls13 bool) (*recordDecrypter, error) {
+	var aead cipher.AEAD
+	var err error
+
+	if params.chacha {
+		aead, err = chacha20poly1305.New(key)
+	} else {
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordDecrypter{aead: aead, iv: iv, tls13: tls13, explicitNonce: !tls13 && !params.chacha}, nil
+}
+
+// open returns the content type and the plaintext of a record.
+func (d *recordDecrypter) open(record tlsRecord) (byte, []byte, error) {
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], d.seq)
+
+	payload := record.payload
+	nonce := make([]byte, 0, 12)
+
+	if d.explicitNonce {
+		if len(payload) < 8 {
+			return 0, nil, errors.New("record too short")
+		}
+
+		nonce, payload = append(append(nonce, d.iv...), payload[:8]...), payload[8:]
+	} else {
+		nonce = append(nonce, d.iv...)
+		for i := range seq {
+			nonce[len(nonce)-8+i] ^= seq[i]
+		}
+	}
+
+	additionalData := record.header
+	if !d.tls13 {
+		if len(payload) < d.aead.Overhead() {
+			return 0, nil, errors.New("record too short")
+		}
+
+		additionalData = append(append(seq[:], record.header[:3]...), 0, 0)
+		binary.BigEndian.PutUint16(additionalData[11:], uint16(len(payload)-d.aead.Overhead()))
+	}
+
+	plaintext, err := d.aead.Open(nil, nonce, payload, additionalData)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	d.seq++
+
+	if !d.tls13 {
+		return record.header[0], plaintext, nil
+	}
+
+	// the content type follows the content and is followed by padding
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	if len(plaintext) == 0 {
+		return 0, nil, errors.New("record without content type")
+	}
+
+	return plaintext[len(plaintext)-1], plaintext[:len(plaintext)-1], nil
+}
+
+func decryptTLS13(records []tlsRecord, params captureSuite, handshakeSecret []byte, trafficSecret []byte) ([]byte, bool) {
+	if trafficSecret == nil {
+		return nil, false
+	}
+
+	traffic, err := newRecordDecrypter(params, expandLabel(params.hash, trafficSecret, "key", params.keyLength), expandLabel(params.hash, trafficSecret, "iv", 12), true)
+	if err != nil {
+		return nil, false
+	}
+
+	// the Finished message of the client is protected with the handshake secret, everything after it with the traffic secret
+	current := traffic
+	if handshakeSecret != nil {
+		if current, err = newRecordDecrypter(params, expandLabel(params.hash, handshakeSecret, "key", params.keyLength), expandLabel(params.hash, handshakeSecret, "iv", 12), true); err != nil {
+			return nil, false
+		}
+	}
+
+	var data []byte
+	for _, record := range records {
+		if record.header[0] != recordTypeApplicationData {
+			continue
+		}
+
+		contentType, plaintext, err := current.open(record)
+		if err != nil && current != traffic {
+			current = traffic
+			contentType, plaintext, err = current.open(record)
+		}
+
+		if err != nil {
+			break
+		}
+
+		switch contentType {
+		case recordTypeHandshake:
+			if containsFinished(plaintext) {
+				current = traffic
+			}
+		case recordTypeApplicationData:
+			data = append(data, plaintext...)
+		}
+	}
+
+	return data, len(data) > 0
+}
+
+// containsFinished reports whether the handshake messages contain a Finished message.
+func containsFinished(messages []byte) bool {
+	for len(messages) >= 4 {
+		if messages[0] == handshakeTypeFinished {
+			return true
+		}
+
+		length := 4 + (int(messages[1])<<16 | int(messages[2])<<8 | int(messages[3]))
+		if length > len(messages) {
+			break
+		}
+
+		messages = messages[length:]
+	}
+
+	return false
+}
+
+func decryptTLS12(records []tlsRecord, params captureSuite, masterSecret []byte, clientRandom []byte, serverRandom []byte) ([]byte, bool) {
+	ivLength := 4
+	if params.chacha {
+		ivLength = 12
+	}
+
+	keyBlock := prf12(params.hash, masterSecret, "key expansion", append(append([]byte{}, serverRandom...), clientRandom...), 2*params.keyLength+2*ivLength)
+
+	decrypter, err := newRecordDecrypter(params, keyBlock[:params.keyLength], keyBlock[2*params.keyLength:2*params.keyLength+ivLength], false)
+	if err != nil {
+		return nil, false
+	}
+
+	var data []byte
+	encrypted := false
+
+	for _, record := range records {
+		if record.header[0] == recordTypeChangeCipherSpec {
+			encrypted = true
+			continue
+		}
+
+		if !encrypted {
+			continue
+		}
+
+		contentType, plaintext, err := decrypter.open(record)
+		if err != nil {
+			break
+		}
+
+		if contentType == recordTypeApplicationData {
+			data = append(data, plaintext...)
+		}
+	}
+
+	return data, len(data) > 0
+}
+
+// expandLabel is HKDF-Expand-Label of tls 1.3 with an empty context.
+func expandLabel(hash func() hash.Hash, secret []byte, label string, length int) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16(uint16(length))
+	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes([]byte("tls13 " + label))
+	})
+	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {})
+
+	out := make([]byte, length)
+	_, _ = io.ReadFull(hkdf.Expand(hash, secret, b.BytesOrPanic()), out)
+
+	return out
+}
+
+// prf12 is the pseudo random function of tls 1.2.
+func prf12(hash func() hash.Hash, secret []byte, label string, seed []byte, length int) []byte {
+	seed = append([]byte(label), seed...)
+
+	mac := hmac.New(hash, secret)
+	mac.Write(seed)
+	a := mac.Sum(nil)
+
+	var out []byte
+	for len(out) < length {
+		mac.Reset()
+		mac.Write(a)
+		mac.Write(seed)
+		out = mac.Sum(out)
+
+		mac.Reset()
+		mac.Write(a)
+		a = mac.Sum(nil)
+	}
+
+	return out[:length]
+}
+
+// h2Preface holds what a client sends at the start of an http2 connection up to its first request headers.
+type h2Preface struct {
+	settings          map[http2.SettingID]uint32
+	settingsOrder     []http2.SettingID
+	connectionFlow    uint32
+	priorities        []http2.Priority
+	pseudoHeaderOrder []string
+}
+
+func parseH2Preface(data []byte) (h2Preface, bool) {
+	if !bytes.HasPrefix(data, []byte(http2.ClientPreface)) {
+		return h2Preface{}, false
+	}
+
+	var preface h2Preface
+	var headerBlock []byte
+
+	framer := http2.NewFramer(io.Discard, bytes.NewReader(data[len(http2.ClientPreface):]))
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return h2Preface{}, false
+		}
+
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() || preface.settings != nil {
+				continue
+			}
+
+			preface.settings = make(map[http2.SettingID]uint32)
+			_ = f.ForeachSetting(func(setting http2.Setting) error {
+				preface.settings[setting.ID] = setting.Val
+				preface.settingsOrder = append(preface.settingsOrder, setting.ID)
+				return nil
+			})
+		case *http2.WindowUpdateFrame:
+			if f.StreamID == 0 && preface.connectionFlow == 0 {
+				preface.connectionFlow = f.Increment
+			}
+		case *http2.PriorityFrame:
+			preface.priorities = append(preface.priorities, http2.Priority{StreamID: f.StreamID, PriorityParam: f.PriorityParam})
+		case *http2.HeadersFrame:
+			headerBlock = append(headerBlock, f.HeaderBlockFragment()...)
+			if !f.HeadersEnded() {
+				continue
+			}
+		case *http2.ContinuationFrame:
+			headerBlock = append(headerBlock, f.HeaderBlockFragment()...)
+			if !f.HeadersEnded() {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if headerBlock == nil {
+			continue
+		}
+
+		fields, err := hpack.NewDecoder(4096, nil).DecodeFull(headerBlock)
+		if err != nil {
+			return h2Preface{}, false
+		}
+
+		for _, field := range fields {
+			if field.IsPseudo() {
+				preface.pseudoHeaderOrder = append(preface.pseudoHeaderOrder, field.Name)
+			}
+		}
+
+		return preface, true
+	}
+}
@@ -176,10 +176,11 @@ func request(requestParams *C.char) *C.char {
 
 func handleErrorResponse(sessionId string, withSession bool, err *tls_client_cffi_src.TLSClientError) *C.char {
 	response := tls_client_cffi_src.Response{
-		Status:  0,
-		Body:    err.Error(),
-		Headers: nil,
-		Cookies: nil,
+		Status:       0,
+		Body:         err.Error(),
+		Headers:      nil,
+		Cookies:      nil,
+		ErrorDetails: tls_client_cffi_src.BuildErrorDetails(err),
 	}
 
 	if withSession {
@@ -3,6 +3,7 @@ package tls_client_cffi_src
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"sync"
@@ -166,6 +167,53 @@ func BuildResponse(sessionId string, withSession bool, resp *http.Response, cook
 	return response, nil
 }
 
+// BuildErrorDetails describes the typed error of the tls client wrapped in err, it returns nil if there is none.
+func BuildErrorDetails(err error) *ErrorDetails {
+	if err == nil {
+		return nil
+	}
+
+	details := &ErrorDetails{}
+
+	var timeoutErr *tls_client.TimeoutError
+	var proxyConnectErr *tls_client.ProxyConnectError
+	var handshakeErr *tls_client.TLSHandshakeError
+	var dialErr *tls_client.DialError
+
+	switch {
+	case errors.As(err, &timeoutErr):
+		details.Type = "timeout"
+		details.TimeoutPhase = string(timeoutErr.Phase)
+	case errors.As(err, &proxyConnectErr):
+		details.Type = "proxyConnect"
+	case errors.As(err, &handshakeErr):
+		details.Type = "tlsHandshake"
+	case errors.As(err, &dialErr):
+		details.Type = "dial"
+	case errors.Is(err, tls_client.ErrUnknownProfile):
+		details.Type = "unknownProfile"
+	default:
+		return nil
+	}
+
+	if errors.As(err, &proxyConnectErr) {
+		details.ProxyStatus = proxyConnectErr.StatusCode
+		details.ProxyHeaders = proxyConnectErr.Header
+		details.ProxyBody = string(proxyConnectErr.Body)
+	}
+
+	if errors.As(err, &handshakeErr) && handshakeErr.HasAlert {
+		alert := handshakeErr.Alert
+		details.TlsAlert = &alert
+	}
+
+	if errors.As(err, &dialErr) {
+		details.Addr = dialErr.Addr
+	}
+
+	return details
+}
+
 func getTlsClient(requestInput RequestInput, sessionId string, withSession bool) (tls_client.HttpClient, error) {
 	clientsLock.Lock()
 	defer clientsLock.Unlock()
@@ -201,7 +249,11 @@ func getTlsClient(requestInput RequestInput, sessionId string, withSession bool)
 	}
 
 	if tlsClientIdentifier != "" {
-		clientProfile = getTlsClientProfile(tlsClientIdentifier)
+		var err error
+		clientProfile, err = tls_client.LookupProfile(tlsClientIdentifier)
+		if err != nil {
+			return nil, fmt.Errorf("can not build http client: %w", err)
+		}
 	}
 
 	timeoutSeconds := tls_client.DefaultTimeoutSeconds
@@ -263,6 +315,26 @@ func getCustomTlsClientProfile(customClientDefinition *CustomTlsClient) (tls.Cli
 		return tls.ClientHelloID{}, nil, nil, nil, 0, nil, err
 	}
 
+	clientHelloId := tls.ClientHelloID{
+		Client:      "Custom",
+		Version:     "1",
+		Seed:        nil,
+		SpecFactory: specFactory,
+	}
+
+	if customClientDefinition.AkamaiFingerprint != "" {
+		if len(customClientDefinition.H2Settings) > 0 || len(customClientDefinition.H2SettingsOrder) > 0 || len(customClientDefinition.PseudoHeaderOrder) > 0 || customClientDefinition.ConnectionFlow != 0 || len(customClientDefinition.PriorityFrames) > 0 {
+			return tls.ClientHelloID{}, nil, nil, nil, 0, nil, fmt.Errorf("akamaiFingerprint can not be combined with h2Settings, h2SettingsOrder, pseudoHeaderOrder, connectionFlow or priorityFrames")
+		}
+
+		settings, settingsOrder, pseudoHeaderOrder, connectionFlow, priorityFrames, err := tls_client.ParseAkamaiFingerprint(customClientDefinition.AkamaiFingerprint)
+		if err != nil {
+			return tls.ClientHelloID{}, nil, nil, nil, 0, nil, err
+		}
+
+		return clientHelloId, settings, settingsOrder, pseudoHeaderOrder, connectionFlow, priorityFrames, nil
+	}
+
 	resolvedH2Settings := make(map[http2.SettingID]uint32)
 	for key, value := range customClientDefinition.H2Settings {
 		resolvedKey, ok := tls_client.H2SettingsMap[key]
@@ -298,26 +370,9 @@ func getCustomTlsClientProfile(customClientDefinition *CustomTlsClient) (tls.Cli
 		})
 	}
 
-	clientHelloId := tls.ClientHelloID{
-		Client:      "Custom",
-		Version:     "1",
-		Seed:        nil,
-		SpecFactory: specFactory,
-	}
-
 	return clientHelloId, resolvedH2Settings, resolvedH2SettingsOrder, pseudoHeaderOrder, connectionFlow, priorityFrames, nil
 }
 
-func getTlsClientProfile(tlsClientIdentifier string) tls_client.ClientProfile {
-	tlsClientProfile, ok := tls_client.MappedTLSClients[tlsClientIdentifier]
-
-	if !ok {
-		return tls_client.DefaultClientProfile
-	}
-
-	return tlsClientProfile
-}
-
 func handleModification(client tls_client.HttpClient, proxyUrl *string, followRedirects bool) (tls_client.HttpClient, bool, error) {
 	changed := false
 
@@ -20,6 +20,10 @@ func (e *TLSClientError) Error() string {
 	return e.err.Error()
 }
 
+func (e *TLSClientError) Unwrap() error {
+	return e.err
+}
+
 type FreeSessionInput struct {
 	SessionId string `json:"sessionId"`
 }
@@ -65,6 +69,7 @@ type CustomTlsClient struct {
 	PseudoHeaderOrder            []string          `json:"pseudoHeaderOrder"`
 	ConnectionFlow               uint32            `json:"connectionFlow"`
 	PriorityFrames               []PriorityFrames  `json:"priorityFrames"`
+	AkamaiFingerprint            string            `json:"akamaiFingerprint"`
 }
 
 type PriorityFrames struct {
@@ -107,4 +112,17 @@ type Response struct {
 	Body      string              `json:"body"`
 	Headers   map[string][]string `json:"headers"`
 	Cookies   map[string]string   `json:"cookies"`
+	// ErrorDetails is only set for failed requests.
+	ErrorDetails *ErrorDetails `json:"errorDetails,omitempty"`
+}
+
+type ErrorDetails struct {
+	// Type is one of timeout, proxyConnect, tlsHandshake, dial or unknownProfile.
+	Type         string              `json:"type"`
+	TimeoutPhase string              `json:"timeoutPhase,omitempty"`
+	Addr         string              `json:"addr,omitempty"`
+	ProxyStatus  int                 `json:"proxyStatus,omitempty"`
+	ProxyHeaders map[string][]string `json:"proxyHeaders,omitempty"`
+	ProxyBody    string              `json:"proxyBody,omitempty"`
+	TlsAlert     *uint8              `json:"tlsAlert,omitempty"`
 }
@@ -1,10 +1,10 @@
 package tls_client
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
-	"time"
 
 	http "github.com/bogdanfinn/fhttp"
 	"github.com/bogdanfinn/fhttp/cookiejar"
@@ -27,6 +27,9 @@ type HttpClient interface {
 	Get(url string) (resp *http.Response, err error)
 	Head(url string) (resp *http.Response, err error)
 	Post(url, contentType string, body io.Reader) (resp *http.Response, err error)
+	GetContext(ctx context.Context, url string) (resp *http.Response, err error)
+	HeadContext(ctx context.Context, url string) (resp *http.Response, err error)
+	PostContext(ctx context.Context, url, contentType string, body io.Reader) (resp *http.Response, err error)
 }
 
 type httpClient struct {
@@ -52,7 +55,7 @@ func ProvideDefaultClient(logger Logger) (HttpClient, error) {
 func NewHttpClient(logger Logger, options ...HttpClientOption) (HttpClient, error) {
 	config := &httpClientConfig{
 		followRedirects: true,
-		timeout:         time.Duration(DefaultTimeoutSeconds) * time.Second,
+		timeouts:        newTimeoutsFromSeconds(DefaultTimeoutSeconds),
 	}
 
 	for _, opt := range options {
@@ -97,16 +100,9 @@ func validateConfig(config *httpClientConfig) error {
 }
 
 func buildFromConfig(config *httpClientConfig) (*http.Client, ClientProfile, error) {
-	var dialer proxy.ContextDialer
-	dialer = newDirectDialer(config.timeout)
-
-	if config.proxyUrl != "" {
-		proxyDialer, err := newConnectDialer(config.proxyUrl, config.timeout)
-		if err != nil {
-			return nil, ClientProfile{}, err
-		}
-
-		dialer = proxyDialer
+	dialer, selector, err := buildDialer(config)
+	if err != nil {
+		return nil, ClientProfile{}, err
 	}
 
 	var redirectFunc func(req *http.Request, via []*http.Request) error
@@ -118,9 +114,17 @@ func buildFromConfig(config *httpClientConfig) (*http.Client, ClientProfile, err
 
 	clientProfile := config.clientProfile
 
+	rtProfile, alpnProtocols, err := applyALPN(clientProfile, config)
+	if err != nil {
+		return nil, ClientProfile{}, err
+	}
+
+	rt := newRoundTripper(rtProfile, config.transportOptions, config.timeouts, config.h2HealthCheck, config.serverNameOverwrite, config.insecureSkipVerify, config.withRandomTlsExtensionOrder, config.forceHttp1, config.h2cMode, alpnProtocols, config.keepProxyPoolsWarm, config.clientTrace)
+	rt.switchProxy(config.proxyUrl, dialer, selector)
+
 	client := &http.Client{
-		Timeout:       config.timeout,
-		Transport:     newRoundTripper(clientProfile, config.transportOptions, config.serverNameOverwrite, config.insecureSkipVerify, config.withRandomTlsExtensionOrder, config.forceHttp1, dialer),
+		Timeout:       config.timeouts.Request,
+		Transport:     rt,
 		CheckRedirect: redirectFunc,
 	}
 
@@ -131,6 +135,49 @@ func buildFromConfig(config *httpClientConfig) (*http.Client, ClientProfile, err
 	return client, clientProfile, nil
 }
 
+// applyALPN rewrites the client hello of the profile to offer the configured protocols, or only http/1.1 if http1 is forced.
+// It returns the offered protocols, nil if the ones of the profile are kept.
+func applyALPN(clientProfile ClientProfile, config *httpClientConfig) (ClientProfile, []string, error) {
+	protocols := config.alpnProtocols
+	if protocols == nil && config.forceHttp1 {
+		protocols = []string{alpnHttp1}
+	}
+
+	if protocols == nil {
+		return clientProfile, nil, nil
+	}
+
+	// profiles without a static spec (e.g. randomized ones) can not be rewritten and would still offer h2
+	clientHelloId, err := clientHelloIdWithALPN(clientProfile.clientHelloId, protocols)
+	if err != nil {
+		return ClientProfile{}, nil, err
+	}
+
+	clientProfile.clientHelloId = clientHelloId
+
+	return clientProfile, protocols, nil
+}
+
+// buildDialer returns the dialer for the configured proxy url. Without a proxy url but with a proxy func
+// the returned selector has to be used to pick the dialer per request.
+func buildDialer(config *httpClientConfig) (proxy.ContextDialer, *proxySelector, error) {
+	if config.proxyUrl != "" {
+		proxyDialer, err := newConnectDialer(config.proxyUrl, config.timeouts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return proxyDialer, nil, nil
+	}
+
+	var selector *proxySelector
+	if config.proxies != nil {
+		selector = newProxySelector(config.proxies, config.timeouts)
+	}
+
+	return newDirectDialer(config.timeouts.Dial), selector, nil
+}
+
 func (c *httpClient) SetFollowRedirect(followRedirect bool) {
 	c.logger.Debug("set follow redirect from %v to %v", c.config.followRedirects, followRedirect)
 
@@ -154,32 +201,44 @@ func (c *httpClient) applyFollowRedirect() {
 
 func (c *httpClient) SetProxy(proxyUrl string) error {
 	c.logger.Debug("set proxy from %s to %s", c.config.proxyUrl, proxyUrl)
+	previousProxyUrl := c.config.proxyUrl
 	c.config.proxyUrl = proxyUrl
+
+	if err := c.applyProxy(); err != nil {
+		c.config.proxyUrl = previousProxyUrl
+		return err
+	}
+
 	c.logger.Info(fmt.Sprintf("set proxy to: %s", proxyUrl))
 
-	return c.applyProxy()
+	return nil
 }
 
 func (c *httpClient) GetProxy() string {
 	return c.config.proxyUrl
 }
 
+// applyProxy switches the round tripper to the configured proxy. Requests which are already in flight
+// finish on their connections, which are closed afterwards.
 func (c *httpClient) applyProxy() error {
-	var dialer proxy.ContextDialer
-	dialer = proxy.Direct
-
 	if c.config.proxyUrl != "" {
 		c.logger.Debug("proxy url %s supplied - using proxy connect dialer", c.config.proxyUrl)
-		proxyDialer, err := newConnectDialer(c.config.proxyUrl, c.config.timeout)
-		if err != nil {
-			c.logger.Error("failed to create proxy connect dialer: %s", err.Error())
-			return err
-		}
+	} else if c.config.proxies != nil {
+		c.logger.Debug("no proxy url supplied - selecting proxy per request")
+	}
 
-		dialer = proxyDialer
+	dialer, selector, err := buildDialer(c.config)
+	if err != nil {
+		c.logger.Error("failed to create proxy connect dialer: %s", err.Error())
+		return err
 	}
 
-	c.Transport = newRoundTripper(c.config.clientProfile, c.config.transportOptions, c.config.serverNameOverwrite, c.config.insecureSkipVerify, c.config.withRandomTlsExtensionOrder, c.config.forceHttp1, dialer)
+	rt, ok := c.Transport.(*roundTripper)
+	if !ok {
+		return fmt.Errorf("transport of type %T does not support switching proxies", c.Transport)
+	}
+
+	rt.switchProxy(c.config.proxyUrl, dialer, selector)
 
 	return nil
 }
@@ -241,10 +300,55 @@ func (c *httpClient) Do(req *http.Request) (*http.Response, error) {
 
 	if err != nil {
 		c.logger.Debug("failed to do request: %s", err.Error())
-		return nil, err
+		return nil, wrapRequestTimeout(err)
 	}
 
 	c.logger.Debug("requested %s : status %d", req.URL.String(), resp.StatusCode)
 
 	return resp, nil
 }
+
+// Get, Head and Post are routed through Do instead of the embedded http.Client, so that their errors are typed as well.
+func (c *httpClient) Get(url string) (*http.Response, error) {
+	return c.GetContext(context.Background(), url)
+}
+
+func (c *httpClient) Head(url string) (*http.Response, error) {
+	return c.HeadContext(context.Background(), url)
+}
+
+func (c *httpClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	return c.PostContext(context.Background(), url, contentType, body)
+}
+
+// GetContext issues a GET which is canceled together with the context, including the dial and tls handshake.
+func (c *httpClient) GetContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+// HeadContext issues a HEAD which is canceled together with the context, including the dial and tls handshake.
+func (c *httpClient) HeadContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+// PostContext issues a POST which is canceled together with the context, including the dial and tls handshake.
+func (c *httpClient) PostContext(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	return c.Do(req)
+}
@@ -0,0 +1,415 @@
+package tls_client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+
+	tls "github.com/bogdanfinn/utls"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+const (
+	recordTypeHandshake      = 22
+	handshakeTypeHello       = 1
+	extensionStatusRequestV2 = 17
+	extensionTokenBinding    = 24
+	extensionOldChannelID    = 30031
+	extensionChannelID       = 30032
+)
+
+// ProfileFromClientHello returns a profile which sends the given ClientHello, captured as tls record(s) or as handshake message,
+// either binary or hex encoded. The http2 fields of the profile are empty, GREASE values, the server name, the session ticket,
+// the padding and the keys are chosen per connection like the captured client does.
+func ProfileFromClientHello(data []byte) (ClientProfile, error) {
+	message, err := clientHelloMessage(data)
+	if err != nil {
+		return ClientProfile{}, fmt.Errorf("failed to parse client hello: %w", err)
+	}
+
+	// the spec is parsed once to fail early, the factory creates fresh extensions for every handshake
+	if _, err := parseClientHello(message); err != nil {
+		return ClientProfile{}, fmt.Errorf("failed to parse client hello: %w", err)
+	}
+
+	clientHelloId := tls.ClientHelloID{
+		Client:  "Custom",
+		Version: "1",
+		SpecFactory: func() (tls.ClientHelloSpec, error) {
+			return parseClientHello(message)
+		},
+	}
+
+	return ClientProfile{clientHelloId: clientHelloId}, nil
+}
+
+// clientHelloMessage returns the ClientHello handshake message without record headers.
+func clientHelloMessage(data []byte) ([]byte, error) {
+	if decoded, ok := decodeHexDump(data); ok {
+		data = decoded
+	}
+
+	if len(data) == 0 {
+		return nil, errors.New("no data")
+	}
+
+	if data[0] == handshakeTypeHello {
+		return data, nil
+	}
+
+	if data[0] != recordTypeHandshake {
+		return nil, fmt.Errorf("neither a handshake record nor a handshake message, the first byte is %d", data[0])
+	}
+
+	// large ClientHellos are split into several records
+	var message []byte
+	s := cryptobyte.String(data)
+	for !s.Empty() {
+		var contentType uint8
+		var fragment cryptobyte.String
+		if !s.ReadUint8(&contentType) || !s.Skip(2) || !s.ReadUint16LengthPrefixed(&fragment) {
+			return nil, errors.New("truncated record")
+		}
+
+		if contentType != recordTypeHandshake {
+			break
+		}
+
+		message = append(message, fragment...)
+		if len(message) >= 4 && len(message) >= 4+(int(message[1])<<16|int(message[2])<<8|int(message[3])) {
+			break
+		}
+	}
+
+	return message, nil
+}
+
+// decodeHexDump decodes hex dumps, whitespace between the bytes is allowed.
+func decodeHexDump(data []byte) ([]byte, bool) {
+	compact := bytes.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return r
+	}, data)
+
+	decoded, err := hex.DecodeString(string(compact))
+	if err != nil || len(compact) == 0 {
+		return nil, false
+	}
+
+	return decoded, true
+}
+
+func parseClientHello(message []byte) (tls.ClientHelloSpec, error) {
+	var spec tls.ClientHelloSpec
+
+	s := cryptobyte.String(message)
+
+	var messageType uint8
+	var body cryptobyte.String
+	var legacyVersion uint16
+	if !s.ReadUint8(&messageType) || !s.ReadUint24LengthPrefixed(&body) {
+		return spec, errors.New("truncated handshake message")
+	}
+
+	if messageType != handshakeTypeHello {
+		return spec, fmt.Errorf("handshake message of type %d is not a ClientHello", messageType)
+	}
+
+	var sessionId, cipherSuites, compressionMethods cryptobyte.String
+	if !body.ReadUint16(&legacyVersion) || !body.Skip(32) || !body.ReadUint8LengthPrefixed(&sessionId) ||
+		!body.ReadUint16LengthPrefixed(&cipherSuites) || !body.ReadUint8LengthPrefixed(&compressionMethods) {
+		return spec, errors.New("truncated ClientHello")
+	}
+
+	for !cipherSuites.Empty() {
+		var suite uint16
+		if !cipherSuites.ReadUint16(&suite) {
+			return spec, errors.New("invalid cipher suites")
+		}
+
+		spec.CipherSuites = append(spec.CipherSuites, unGREASE(suite))
+	}
+
+	spec.CompressionMethods = append([]uint8{}, compressionMethods...)
+
+	var extensions cryptobyte.String
+	if !body.Empty() && !body.ReadUint16LengthPrefixed(&extensions) {
+		return spec, errors.New("invalid extensions")
+	}
+
+	hasSupportedVersions := false
+	for !extensions.Empty() {
+		var id uint16
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&id) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return spec, errors.New("truncated extension")
+		}
+
+		extension, err := parseExtension(id, data)
+		if err != nil {
+			// sent as captured, utls could not write it otherwise
+			extension = &tls.GenericExtension{Id: id, Data: append([]byte{}, data...)}
+		}
+
+		if _, ok := extension.(*tls.SupportedVersionsExtension); ok {
+			hasSupportedVersions = true
+		}
+
+		spec.Extensions = append(spec.Extensions, extension)
+	}
+
+	// the versions are taken from the extension if there is one, like utls does for its own specs
+	if !hasSupportedVersions {
+		spec.TLSVersMin = tls.VersionTLS10
+		spec.TLSVersMax = legacyVersion
+	}
+
+	return spec, nil
+}
+
+// parseExtension returns the utls extension for the extension data.
+// Extensions utls would not write as captured are sent as generic extensions with the captured data.
+func parseExtension(id uint16, data cryptobyte.String) (tls.TLSExtension, error) {
+	if isGREASE(id) {
+		return &tls.UtlsGREASEExtension{}, nil
+	}
+
+	var extension tls.TLSExtension
+	original := append([]byte{}, data...)
+	// the extensions without parameters are compared with what utls writes, the others have to be parsed completely
+	hasParameters := true
+
+	switch id {
+	case tls.ExtensionServerName:
+		// the server name is the one of the request
+		return &tls.SNIExtension{}, nil
+	case tls.ExtensionSessionTicket:
+		return &tls.SessionTicketExtension{}, nil
+	case tls.ExtensionPadding:
+		return &tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle}, nil
+	case tls.ExtensionPreSharedKey:
+		return &tls.PreSharedKeyExtension{}, nil
+	case tls.ExtensionKeyShare:
+		var keyShares cryptobyte.String
+		if !data.ReadUint16LengthPrefixed(&keyShares) {
+			return nil, errors.New("invalid key shares")
+		}
+
+		ext := &tls.KeyShareExtension{}
+		for !keyShares.Empty() {
+			var group uint16
+			var key cryptobyte.String
+			if !keyShares.ReadUint16(&group) || !keyShares.ReadUint16LengthPrefixed(&key) {
+				return nil, errors.New("invalid key share")
+			}
+
+			keyShare := tls.KeyShare{Group: tls.CurveID(unGREASE(group))}
+			// GREASE key shares keep their data, the other keys are generated per connection
+			if isGREASE(group) {
+				keyShare.Data = append([]byte{}, key...)
+			}
+
+			ext.KeyShares = append(ext.KeyShares, keyShare)
+		}
+
+		return ext, nil
+	case tls.ExtensionStatusRequest:
+		extension = &tls.StatusRequestExtension{}
+		hasParameters = false
+	case extensionStatusRequestV2:
+		extension = &tls.StatusRequestV2Extension{}
+		hasParameters = false
+	case tls.ExtensionSupportedCurves:
+		groups, err := readUint16List(&data)
+		if err != nil {
+			return nil, err
+		}
+
+		ext := &tls.SupportedCurvesExtension{}
+		for _, group := range groups {
+			ext.Curves = append(ext.Curves, tls.CurveID(unGREASE(group)))
+		}
+
+		extension = ext
+	case tls.ExtensionSupportedPoints:
+		var points cryptobyte.String
+		if !data.ReadUint8LengthPrefixed(&points) {
+			return nil, errors.New("invalid point formats")
+		}
+
+		extension = &tls.SupportedPointsExtension{SupportedPoints: append([]uint8{}, points...)}
+	case tls.ExtensionSignatureAlgorithms, tls.ExtensionSignatureAlgorithmsCert, tls.ExtensionDelegatedCredentials:
+		values, err := readUint16List(&data)
+		if err != nil {
+			return nil, err
+		}
+
+		var schemes []tls.SignatureScheme
+		for _, value := range values {
+			schemes = append(schemes, tls.SignatureScheme(value))
+		}
+
+		switch id {
+		case tls.ExtensionSignatureAlgorithms:
+			extension = &tls.SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: schemes}
+		case tls.ExtensionSignatureAlgorithmsCert:
+			extension = &tls.SignatureAlgorithmsCertExtension{SupportedSignatureAlgorithms: schemes}
+		default:
+			extension = &tls.DelegatedCredentialsExtension{AlgorithmsSignature: schemes}
+		}
+	case tls.ExtensionRenegotiationInfo:
+		extension = &tls.RenegotiationInfoExtension{Renegotiation: tls.RenegotiateOnceAsClient}
+		hasParameters = false
+	case tls.ExtensionALPN, tls.ExtensionALPS:
+		var list cryptobyte.String
+		if !data.ReadUint16LengthPrefixed(&list) {
+			return nil, errors.New("invalid protocol list")
+		}
+
+		var protocols []string
+		for !list.Empty() {
+			var protocol cryptobyte.String
+			if !list.ReadUint8LengthPrefixed(&protocol) {
+				return nil, errors.New("invalid protocol")
+			}
+
+			protocols = append(protocols, string(protocol))
+		}
+
+		if id == tls.ExtensionALPN {
+			extension = &tls.ALPNExtension{AlpnProtocols: protocols}
+		} else {
+			extension = &tls.ApplicationSettingsExtension{SupportedProtocols: protocols}
+		}
+	case tls.ExtensionSCT:
+		extension = &tls.SCTExtension{}
+		hasParameters = false
+	case tls.ExtensionExtendedMasterSecret:
+		extension = &tls.UtlsExtendedMasterSecretExtension{}
+		hasParameters = false
+	case tls.ExtensionCompressCertificate:
+		var list cryptobyte.String
+		if !data.ReadUint8LengthPrefixed(&list) {
+			return nil, errors.New("invalid algorithms")
+		}
+
+		ext := &tls.UtlsCompressCertExtension{}
+		for !list.Empty() {
+			var algorithm uint16
+			if !list.ReadUint16(&algorithm) {
+				return nil, errors.New("invalid algorithm")
+			}
+
+			ext.Algorithms = append(ext.Algorithms, tls.CertCompressionAlgo(algorithm))
+		}
+
+		extension = ext
+	case tls.ExtensionRecordSizeLimit:
+		ext := &tls.FakeRecordSizeLimitExtension{}
+		if !data.ReadUint16(&ext.Limit) {
+			return nil, errors.New("invalid limit")
+		}
+
+		extension = ext
+	case tls.ExtensionPSKModes:
+		var modes cryptobyte.String
+		if !data.ReadUint8LengthPrefixed(&modes) {
+			return nil, errors.New("invalid modes")
+		}
+
+		extension = &tls.PSKKeyExchangeModesExtension{Modes: append([]uint8{}, modes...)}
+	case tls.ExtensionSupportedVersions:
+		var list cryptobyte.String
+		if !data.ReadUint8LengthPrefixed(&list) {
+			return nil, errors.New("invalid versions")
+		}
+
+		ext := &tls.SupportedVersionsExtension{}
+		for !list.Empty() {
+			var version uint16
+			if !list.ReadUint16(&version) {
+				return nil, errors.New("invalid version")
+			}
+
+			ext.Versions = append(ext.Versions, unGREASE(version))
+		}
+
+		extension = ext
+	case tls.ExtensionCookie:
+		var cookie cryptobyte.String
+		if !data.ReadUint16LengthPrefixed(&cookie) {
+			return nil, errors.New("invalid cookie")
+		}
+
+		extension = &tls.CookieExtension{Cookie: append([]byte{}, cookie...)}
+	case extensionTokenBinding:
+		ext := &tls.FakeTokenBindingExtension{}
+
+		var parameters cryptobyte.String
+		if !data.ReadUint8(&ext.MajorVersion) || !data.ReadUint8(&ext.MinorVersion) || !data.ReadUint8LengthPrefixed(&parameters) {
+			return nil, errors.New("invalid token binding")
+		}
+
+		ext.KeyParameters = append([]uint8{}, parameters...)
+		extension = ext
+	case extensionChannelID, extensionOldChannelID:
+		extension = &tls.FakeChannelIDExtension{OldExtensionID: id == extensionOldChannelID}
+		hasParameters = false
+	case tls.ExtensionNextProtoNeg:
+		extension = &tls.NPNExtension{}
+		hasParameters = false
+	default:
+		return &tls.GenericExtension{Id: id, Data: append([]byte{}, data...)}, nil
+	}
+
+	if (hasParameters && !data.Empty()) || (!hasParameters && !writesExtension(extension, id, original)) {
+		return &tls.GenericExtension{Id: id, Data: original}, nil
+	}
+
+	return extension, nil
+}
+
+// writesExtension reports whether utls writes the extension with the id and the data.
+func writesExtension(extension tls.TLSExtension, id uint16, data []byte) bool {
+	written := make([]byte, extension.Len())
+	if _, err := extension.Read(written); err != nil && err != io.EOF {
+		return false
+	}
+
+	return len(written) >= 4 && uint16(written[0])<<8|uint16(written[1]) == id && bytes.Equal(written[4:], data)
+}
+
+func readUint16List(data *cryptobyte.String) ([]uint16, error) {
+	var list cryptobyte.String
+	if !data.ReadUint16LengthPrefixed(&list) || len(list)%2 != 0 {
+		return nil, errors.New("invalid list")
+	}
+
+	var values []uint16
+	for !list.Empty() {
+		var value uint16
+		list.ReadUint16(&value)
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// isGREASE reports whether the value is one of the reserved GREASE values of RFC 8701.
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+func unGREASE(value uint16) uint16 {
+	if isGREASE(value) {
+		return tls.GREASE_PLACEHOLDER
+	}
+
+	return value
+}
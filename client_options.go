@@ -20,19 +20,49 @@ type TransportOptions struct {
 	ReadBufferSize         int   // If zero, a default (currently 4KB) is used.
 }
 
+// H2HealthCheck pings http2 connections which received no frame for ReadIdleTimeout and closes them
+// if the ping is not answered within PingTimeout, so that dead connections are replaced instead of hanging requests.
+// A zero ReadIdleTimeout disables the health check, a zero PingTimeout defaults to 15 seconds.
+type H2HealthCheck struct {
+	ReadIdleTimeout time.Duration
+	PingTimeout     time.Duration
+}
+
+// Timeouts limits the single phases of a request. A zero value disables the respective timeout.
+type Timeouts struct {
+	// Dial limits establishing the tcp connection to the destination or the proxy.
+	Dial time.Duration
+	// ProxyConnect limits the CONNECT request including the proxy authentication, or the socks5 and ssh handshakes.
+	ProxyConnect time.Duration
+	// TLSHandshake limits the tls handshake with the destination or a https proxy.
+	TLSHandshake time.Duration
+	// ResponseHeader limits waiting for the response headers once the request is written.
+	ResponseHeader time.Duration
+	// IdleConnection is how long an idle connection stays in the pool.
+	IdleConnection time.Duration
+	// Request limits the whole request including redirects and reading the response body.
+	Request time.Duration
+}
+
 type httpClientConfig struct {
 	debug                       bool
 	followRedirects             bool
 	insecureSkipVerify          bool
 	proxyUrl                    string
+	proxies                     proxyListFunc
+	keepProxyPoolsWarm          bool
 	serverNameOverwrite         string
 	transportOptions            *TransportOptions
 	cookieJar                   http.CookieJar
 	clientProfile               ClientProfile
 	withRandomTlsExtensionOrder bool
 	forceHttp1                  bool
+	alpnProtocols               []string
+	h2cMode                     H2CMode
+	clientTrace                 *ClientTrace
 	skipExistingCookie          bool
-	timeout                     time.Duration
+	timeouts                    Timeouts
+	h2HealthCheck               H2HealthCheck
 }
 
 func WithProxyUrl(proxyUrl string) HttpClientOption {
@@ -41,6 +71,36 @@ func WithProxyUrl(proxyUrl string) HttpClientOption {
 	}
 }
 
+// WithProxyFunc selects the proxy per request. A proxy set with WithProxyUrl or SetProxy takes precedence.
+func WithProxyFunc(proxyFunc ProxyFunc) HttpClientOption {
+	return func(config *httpClientConfig) {
+		config.proxies = proxyFunc.list()
+	}
+}
+
+// WithProxyFromEnvironment uses the proxies configured in the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func WithProxyFromEnvironment() HttpClientOption {
+	return func(config *httpClientConfig) {
+		config.proxies = ProxyFuncFromEnvironment().list()
+	}
+}
+
+// WithProxyPac selects the proxy per request by evaluating a proxy auto-config script.
+// Like browsers do, the entries of the result are tried in order until one of them can be reached.
+func WithProxyPac(evaluator PacEvaluator) HttpClientOption {
+	return func(config *httpClientConfig) {
+		config.proxies = pacProxies(evaluator)
+	}
+}
+
+// WithWarmProxyPools keeps the connections of a proxy open after switching to another one with SetProxy,
+// so that switching back does not need new connections.
+func WithWarmProxyPools() HttpClientOption {
+	return func(config *httpClientConfig) {
+		config.keepProxyPoolsWarm = true
+	}
+}
+
 func WithCookieJar(jar http.CookieJar) HttpClientOption {
 	return func(config *httpClientConfig) {
 		config.cookieJar = jar
@@ -54,9 +114,27 @@ func WithNewCookieJar() HttpClientOption {
 	}
 }
 
+// WithTimeout limits the whole request, the dial and the proxy CONNECT to the given seconds each.
 func WithTimeout(timeout int) HttpClientOption {
 	return func(config *httpClientConfig) {
-		config.timeout = time.Second * time.Duration(timeout)
+		config.timeouts = newTimeoutsFromSeconds(timeout)
+	}
+}
+
+// WithTimeouts configures the timeouts of the single phases of a request, replacing the ones of WithTimeout.
+func WithTimeouts(timeouts Timeouts) HttpClientOption {
+	return func(config *httpClientConfig) {
+		config.timeouts = timeouts
+	}
+}
+
+func newTimeoutsFromSeconds(seconds int) Timeouts {
+	timeout := time.Second * time.Duration(seconds)
+
+	return Timeouts{
+		Dial:         timeout,
+		ProxyConnect: timeout,
+		Request:      timeout,
 	}
 }
 
@@ -90,12 +168,43 @@ func WithInsecureSkipVerify() HttpClientOption {
 	}
 }
 
+// WithForceHttp1 speaks http/1.1 only. Unless WithALPNProtocols is used, the client hello offers only http/1.1 as well,
+// which fails for profiles without a static client hello spec (e.g. randomized ones).
 func WithForceHttp1() HttpClientOption {
 	return func(config *httpClientConfig) {
 		config.forceHttp1 = true
 	}
 }
 
+// WithClientTrace runs the hooks of the trace for every request which has no ClientTrace in its context.
+func WithClientTrace(trace *ClientTrace) HttpClientOption {
+	return func(config *httpClientConfig) {
+		config.clientTrace = trace
+	}
+}
+
+// WithH2HealthCheck enables ping based health checks of http2 connections.
+func WithH2HealthCheck(healthCheck H2HealthCheck) HttpClientOption {
+	return func(config *httpClientConfig) {
+		config.h2HealthCheck = healthCheck
+	}
+}
+
+// WithH2C speaks HTTP/2 without tls with http:// urls, using the http2 settings, priorities and header order of the profile.
+func WithH2C(mode H2CMode) HttpClientOption {
+	return func(config *httpClientConfig) {
+		config.h2cMode = mode
+	}
+}
+
+// WithALPNProtocols replaces the application layer protocols offered by the client hello of the profile.
+// Requests fail if the server selects a protocol which is not in the list.
+func WithALPNProtocols(protocols ...string) HttpClientOption {
+	return func(config *httpClientConfig) {
+		config.alpnProtocols = protocols
+	}
+}
+
 func WithSkipExistingCookie() HttpClientOption {
 	return func(config *httpClientConfig) {
 		config.skipExistingCookie = true
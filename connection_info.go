@@ -0,0 +1,265 @@
+package tls_client
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/binary"
+	"net"
+	"net/url"
+	"sync"
+
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/bogdanfinn/fhttp/httptrace"
+	utls "github.com/bogdanfinn/utls"
+)
+
+// ConnectionInfo describes the connection a response was received on.
+type ConnectionInfo struct {
+	// Protocol is the protocol of the response, e.g. "HTTP/1.1" or "HTTP/2.0".
+	Protocol string
+	// TLSVersion, CipherSuite, NegotiatedProtocol and PeerCertificates are empty for http:// urls.
+	TLSVersion         uint16
+	CipherSuite        uint16
+	NegotiatedProtocol string
+	PeerCertificates   []*x509.Certificate
+	// ServerH2Settings are the settings of the first SETTINGS frame of the server in the order they were sent.
+	ServerH2Settings []http2.Setting
+	LocalAddr        net.Addr
+	RemoteAddr       net.Addr
+	// Proxy is the proxy url without password, empty for direct connections.
+	Proxy  string
+	Reused bool
+}
+
+type connectionInfoContextKey struct{}
+
+// GetConnectionInfo returns the connection info of a response received by a tls client, or nil for other responses.
+func GetConnectionInfo(resp *http.Response) *ConnectionInfo {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+
+	info, _ := resp.Request.Context().Value(connectionInfoContextKey{}).(*ConnectionInfo)
+
+	return info
+}
+
+// withConnectionInfo returns a request whose response will carry the connection info, which is filled by the returned func.
+func withConnectionInfo(req *http.Request) (*http.Request, func(resp *http.Response)) {
+	info := &ConnectionInfo{}
+
+	var gotConn httptrace.GotConnInfo
+	trace := &httptrace.ClientTrace{
+		GotConn: func(connInfo httptrace.GotConnInfo) {
+			gotConn = connInfo
+		},
+	}
+
+	ctx := context.WithValue(httptrace.WithClientTrace(req.Context(), trace), connectionInfoContextKey{}, info)
+
+	return req.WithContext(ctx), func(resp *http.Response) {
+		info.Protocol = resp.Proto
+		info.Reused = gotConn.Reused
+
+		if conn, ok := gotConn.Conn.(infoConnection); ok {
+			conn.fill(info, resp.ProtoMajor == 2)
+		}
+	}
+}
+
+type infoConnection interface {
+	fill(info *ConnectionInfo, http2 bool)
+}
+
+// infoConn records what the connection info needs to know about a connection, including the SETTINGS frame the server sends first with HTTP/2.
+// If the trace asks for http2 frames the frames after it are parsed as well.
+type infoConn struct {
+	net.Conn
+	proxyUrl string
+	trace    *ClientTrace
+
+	mu       sync.Mutex
+	sniffing bool
+	frames   int
+	// frame holds the frame read so far, skip the rest of a frame which is not kept
+	frame    []byte
+	skip     int
+	settings []http2.Setting
+}
+
+// tlsInfoConn is an infoConn for tls connections, the http2 transport takes the tls state of the response from ConnectionState.
+type tlsInfoConn struct {
+	*infoConn
+	uconn *utls.UConn
+}
+
+func newInfoConn(conn net.Conn, uconn *utls.UConn, proxyUrl string, trace *ClientTrace) net.Conn {
+	if u, err := url.Parse(proxyUrl); err == nil && proxyUrl != "" {
+		proxyUrl = u.Redacted()
+	}
+
+	c := &infoConn{Conn: conn, proxyUrl: proxyUrl, trace: trace, sniffing: true}
+	if uconn == nil {
+		return c
+	}
+
+	return &tlsInfoConn{infoConn: c, uconn: uconn}
+}
+
+func (c *infoConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.sniff(p[:n])
+	}
+
+	return n, err
+}
+
+// sniff parses the frames read from the connection as long as they are needed and the connection looks like http2.
+func (c *infoConn) sniff(p []byte) {
+	var events []func()
+
+	c.mu.Lock()
+
+	for len(p) > 0 && c.sniffing {
+		if c.skip > 0 {
+			n := c.skip
+			if n > len(p) {
+				n = len(p)
+			}
+
+			c.skip -= n
+			p = p[n:]
+			continue
+		}
+
+		size := 9
+		if len(c.frame) >= 9 {
+			size += frameLength(c.frame)
+		}
+
+		n := size - len(c.frame)
+		if n > len(p) {
+			n = len(p)
+		}
+
+		c.frame = append(c.frame, p[:n]...)
+		p = p[n:]
+
+		if len(c.frame) == 9 && !c.frameHeader() {
+			continue
+		}
+
+		if len(c.frame) == 9+frameLength(c.frame) {
+			events = append(events, c.frameDone()...)
+		}
+	}
+
+	c.mu.Unlock()
+
+	for _, event := range events {
+		event()
+	}
+}
+
+func frameLength(header []byte) int {
+	return int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+}
+
+// frameHeader checks the header of the frame in c.frame and reports whether the frame is kept.
+func (c *infoConn) frameHeader() bool {
+	length := frameLength(c.frame)
+	frameType, flags, streamId := http2.FrameType(c.frame[3]), http2.Flags(c.frame[4]), binary.BigEndian.Uint32(c.frame[5:9])&0x7fffffff
+
+	// anything else than a SETTINGS frame is not the start of an http2 connection, e.g. an http1 response
+	if c.frames == 0 && (frameType != http2.FrameSettings || flags.Has(http2.FlagSettingsAck) || streamId != 0 || length%6 != 0 || length > 16384) {
+		c.sniffing = false
+		c.frame = nil
+		return false
+	}
+
+	isSettings := frameType == http2.FrameSettings && !flags.Has(http2.FlagSettingsAck) && length%6 == 0
+	if !isSettings && frameType != http2.FrameGoAway {
+		c.frames++
+		c.skip = length
+		c.frame = c.frame[:0]
+		return false
+	}
+
+	return true
+}
+
+// frameDone handles the complete frame in c.frame and returns the trace hooks to run for it.
+func (c *infoConn) frameDone() []func() {
+	var events []func()
+
+	payload := c.frame[9:]
+	trace := c.trace
+
+	switch http2.FrameType(c.frame[3]) {
+	case http2.FrameSettings:
+		settings := make([]http2.Setting, 0, len(payload)/6)
+		for ; len(payload) >= 6; payload = payload[6:] {
+			settings = append(settings, http2.Setting{
+				ID:  http2.SettingID(binary.BigEndian.Uint16(payload[:2])),
+				Val: binary.BigEndian.Uint32(payload[2:6]),
+			})
+		}
+
+		if c.frames == 0 {
+			c.settings = settings
+		}
+
+		events = append(events, func() {
+			trace.h2SettingsReceived(settings)
+		})
+	case http2.FrameGoAway:
+		if len(payload) >= 8 {
+			lastStreamId := binary.BigEndian.Uint32(payload[:4]) & 0x7fffffff
+			code := http2.ErrCode(binary.BigEndian.Uint32(payload[4:8]))
+			debugData := append([]byte(nil), payload[8:]...)
+
+			events = append(events, func() {
+				trace.h2GoAwayReceived(lastStreamId, code, debugData)
+			})
+		}
+	}
+
+	c.frames++
+	c.frame = c.frame[:0]
+
+	// without a trace for the frames only the first SETTINGS frame is of interest
+	if !trace.tracesH2Frames() {
+		c.sniffing = false
+		c.frame = nil
+	}
+
+	return events
+}
+
+func (c *infoConn) fill(info *ConnectionInfo, http2 bool) {
+	info.LocalAddr = c.LocalAddr()
+	info.RemoteAddr = c.RemoteAddr()
+	info.Proxy = c.proxyUrl
+
+	if http2 {
+		c.mu.Lock()
+		info.ServerH2Settings = c.settings
+		c.mu.Unlock()
+	}
+}
+
+func (c *tlsInfoConn) fill(info *ConnectionInfo, http2 bool) {
+	c.infoConn.fill(info, http2)
+
+	state := c.uconn.ConnectionState()
+	info.TLSVersion = state.Version
+	info.CipherSuite = state.CipherSuite
+	info.NegotiatedProtocol = state.NegotiatedProtocol
+	info.PeerCertificates = state.PeerCertificates
+}
+
+func (c *tlsInfoConn) ConnectionState() utls.ConnectionState {
+	return c.uconn.ConnectionState()
+}
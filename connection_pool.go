@@ -0,0 +1,167 @@
+package tls_client
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	http "github.com/bogdanfinn/fhttp"
+	"golang.org/x/net/proxy"
+)
+
+// connectionPool holds the transports and negotiated connections of one proxy ("" for direct connections).
+// A retired pool is closed as soon as its last in-flight request is finished.
+type connectionPool struct {
+	proxyUrl    string
+	transports  map[connectionKey]http.RoundTripper
+	connections map[connectionKey]net.Conn
+	// negotiations holds the first dial of each key until its transport is known.
+	negotiations map[connectionKey]*negotiation
+
+	inFlight int
+	retired  bool
+}
+
+// connectionKey identifies the connections which can be shared by requests.
+// A connection is never reused for a request which differs in any of the fields.
+type connectionKey struct {
+	scheme string
+	// addr is the host and port of the destination, the port defaults to the one of the scheme.
+	addr       string
+	serverName string
+	proxyUrl   string
+	profile    string
+}
+
+// negotiation is shared by all requests waiting for the transport of one key.
+type negotiation struct {
+	done chan struct{}
+	err  error
+	// canceled is set if the negotiation failed because the context of its request is done.
+	canceled bool
+}
+
+type idleConnectionsCloser interface {
+	CloseIdleConnections()
+}
+
+func newConnectionPool(proxyUrl string) *connectionPool {
+	return &connectionPool{
+		proxyUrl:     proxyUrl,
+		transports:   make(map[connectionKey]http.RoundTripper),
+		connections:  make(map[connectionKey]net.Conn),
+		negotiations: make(map[connectionKey]*negotiation),
+	}
+}
+
+func (p *connectionPool) closeIdleConnections() {
+	for _, t := range p.transports {
+		if closer, ok := t.(idleConnectionsCloser); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+
+	for key, conn := range p.connections {
+		_ = conn.Close()
+		delete(p.connections, key)
+	}
+}
+
+// getPool returns the pool of the given proxy url. The caller has to hold cachedTransportsLck.
+func (rt *roundTripper) getPool(proxyUrl string) *connectionPool {
+	pool, ok := rt.pools[proxyUrl]
+	if !ok {
+		pool = newConnectionPool(proxyUrl)
+		rt.pools[proxyUrl] = pool
+	}
+
+	return pool
+}
+
+// switchProxy routes all following requests through the given dialer, or through the dialers of the selector if it is not nil.
+// Connections of other proxies are closed after their in-flight requests are finished, unless pools are kept warm.
+func (rt *roundTripper) switchProxy(proxyUrl string, dialer proxy.ContextDialer, selector *proxySelector) {
+	rt.cachedTransportsLck.Lock()
+	defer rt.cachedTransportsLck.Unlock()
+
+	rt.proxyGeneration++
+	rt.proxyUrl = proxyUrl
+	rt.dialer = dialer
+	rt.proxySelector = selector
+
+	if rt.keepPoolsWarm {
+		return
+	}
+
+	for _, pool := range rt.pools {
+		if pool.proxyUrl == proxyUrl && selector == nil {
+			continue
+		}
+
+		pool.retired = true
+		rt.closeRetiredPool(pool)
+	}
+}
+
+func (rt *roundTripper) requestFinished(pool *connectionPool) {
+	rt.cachedTransportsLck.Lock()
+	defer rt.cachedTransportsLck.Unlock()
+
+	pool.inFlight--
+	rt.closeRetiredPool(pool)
+}
+
+// closeRetiredPool closes and forgets the pool if it is retired and idle. The caller has to hold cachedTransportsLck.
+func (rt *roundTripper) closeRetiredPool(pool *connectionPool) {
+	if !pool.retired || pool.inFlight > 0 {
+		return
+	}
+
+	pool.closeIdleConnections()
+
+	if rt.pools[pool.proxyUrl] == pool {
+		delete(rt.pools, pool.proxyUrl)
+	}
+}
+
+// CloseIdleConnections closes the idle connections of all pools.
+func (rt *roundTripper) CloseIdleConnections() {
+	rt.cachedTransportsLck.Lock()
+	defer rt.cachedTransportsLck.Unlock()
+
+	for _, pool := range rt.pools {
+		pool.closeIdleConnections()
+	}
+}
+
+// trackedBody calls onDone once the response body was read completely or closed.
+type trackedBody struct {
+	io.ReadCloser
+	once   sync.Once
+	onDone func()
+}
+
+func newTrackedBody(body io.ReadCloser, onDone func()) io.ReadCloser {
+	if body == nil {
+		onDone()
+		return body
+	}
+
+	return &trackedBody{ReadCloser: body, onDone: onDone}
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.onDone)
+	}
+
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.onDone)
+
+	return err
+}
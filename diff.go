@@ -0,0 +1,451 @@
+package tls_client
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bogdanfinn/fhttp/http2"
+)
+
+// ProfileDiff lists the differences of two profiles a and b, the fields in which they do not differ are empty.
+// Values are written like in the declarative profile format, e.g. cipher suites, groups and settings by their names.
+type ProfileDiff struct {
+	// ClientHello holds the differences of tlsVersionMin, tlsVersionMax and randomExtensionOrder.
+	ClientHello        []ValueDiff
+	CipherSuites       ListDiff
+	CompressionMethods ListDiff
+	// Extensions compares the names of the extensions, GREASE extensions are named grease.
+	Extensions ListDiff
+	// ExtensionParameters holds the differences of the parameters of extensions both profiles send, except for the key shares.
+	ExtensionParameters []ExtensionDiff
+	// KeyShares compares the groups of the key shares, followed by the key if the profile has a fixed one.
+	KeyShares ListDiff
+	// Settings compares the values of the http2 settings, profiles without settings send DefaultH2Settings.
+	Settings       []ValueDiff
+	SettingsOrder  ListDiff
+	ConnectionFlow ValueDiff
+	// Priorities are written like in the akamai fingerprint, streamId:exclusive:streamDep:weight with the weight of the frame plus one.
+	Priorities        ListDiff
+	PseudoHeaderOrder ListDiff
+}
+
+// ValueDiff is a value which differs, A or B is empty if the profile does not have it.
+type ValueDiff struct {
+	Name string
+	A    string
+	B    string
+}
+
+// ListDiff is a list which differs, Removed are the entries only a has and Added the ones only b has.
+type ListDiff struct {
+	A       []string
+	B       []string
+	Added   []string
+	Removed []string
+	// Reordered is true if the entries both lists have are in another order.
+	Reordered bool
+}
+
+// ExtensionDiff holds the parameters which differ for an extension both profiles send.
+type ExtensionDiff struct {
+	Name       string
+	Parameters []ValueDiff
+}
+
+// DiffProfiles compares the client hellos and the http2 fingerprints of the profiles.
+func DiffProfiles(a, b ClientProfile) (ProfileDiff, error) {
+	fileA, err := encodeProfile(a)
+	if err != nil {
+		return ProfileDiff{}, fmt.Errorf("failed to diff client profiles: %w", err)
+	}
+
+	fileB, err := encodeProfile(b)
+	if err != nil {
+		return ProfileDiff{}, fmt.Errorf("failed to diff client profiles: %w", err)
+	}
+
+	helloA, helloB := fileA.ClientHello, fileB.ClientHello
+
+	diff := ProfileDiff{
+		ClientHello: diffValues([]ValueDiff{
+			{Name: "tlsVersionMin", A: string(helloA.TLSVersionMin), B: string(helloB.TLSVersionMin)},
+			{Name: "tlsVersionMax", A: string(helloA.TLSVersionMax), B: string(helloB.TLSVersionMax)},
+			{Name: "randomExtensionOrder", A: strconv.FormatBool(helloA.RandomExtensionOrder), B: strconv.FormatBool(helloB.RandomExtensionOrder)},
+		}),
+		CipherSuites:       diffLists(profileValueStrings(helloA.CipherSuites), profileValueStrings(helloB.CipherSuites)),
+		CompressionMethods: diffLists(profileValueStrings(helloA.CompressionMethods), profileValueStrings(helloB.CompressionMethods)),
+		Extensions:         diffLists(extensionNames(helloA.Extensions), extensionNames(helloB.Extensions)),
+		KeyShares:          diffLists(keyShareStrings(helloA.Extensions), keyShareStrings(helloB.Extensions)),
+		ConnectionFlow:     diffValue("connectionFlow", strconv.FormatUint(uint64(a.connectionFlow), 10), strconv.FormatUint(uint64(b.connectionFlow), 10)),
+		Priorities:         diffLists(priorityStrings(a.priorities), priorityStrings(b.priorities)),
+		PseudoHeaderOrder:  diffLists(a.pseudoHeaderOrder, b.pseudoHeaderOrder),
+	}
+
+	diff.ExtensionParameters, err = diffExtensionParameters(helloA.Extensions, helloB.Extensions)
+	if err != nil {
+		return ProfileDiff{}, fmt.Errorf("failed to diff client profiles: %w", err)
+	}
+
+	settingsA, orderA := sentSettings(a)
+	settingsB, orderB := sentSettings(b)
+
+	diff.SettingsOrder = diffLists(orderA, orderB)
+
+	var settings []ValueDiff
+	for _, name := range append(append([]string(nil), orderA...), orderB...) {
+		if containsValueDiff(settings, name) {
+			continue
+		}
+
+		settings = append(settings, ValueDiff{Name: name, A: settingsA[name], B: settingsB[name]})
+	}
+
+	diff.Settings = diffValues(settings)
+
+	return diff, nil
+}
+
+// Empty returns true if the profiles do not differ.
+func (d ProfileDiff) Empty() bool {
+	return len(d.ClientHello) == 0 && d.CipherSuites.Empty() && d.CompressionMethods.Empty() && d.Extensions.Empty() &&
+		len(d.ExtensionParameters) == 0 && d.KeyShares.Empty() && len(d.Settings) == 0 && d.SettingsOrder.Empty() &&
+		d.ConnectionFlow.Empty() && d.Priorities.Empty() && d.PseudoHeaderOrder.Empty()
+}
+
+// String renders the differences with one section per field, removed entries start with - and added ones with +.
+func (d ProfileDiff) String() string {
+	if d.Empty() {
+		return "profiles do not differ\n"
+	}
+
+	var sb strings.Builder
+
+	writeValues(&sb, "client hello", d.ClientHello)
+	writeList(&sb, "cipher suites", d.CipherSuites)
+	writeList(&sb, "compression methods", d.CompressionMethods)
+	writeList(&sb, "extensions", d.Extensions)
+
+	for _, extension := range d.ExtensionParameters {
+		writeValues(&sb, "extension "+extension.Name, extension.Parameters)
+	}
+
+	writeList(&sb, "key shares", d.KeyShares)
+	writeValues(&sb, "http2 settings", d.Settings)
+	writeList(&sb, "http2 settings order", d.SettingsOrder)
+
+	if !d.ConnectionFlow.Empty() {
+		fmt.Fprintf(&sb, "connection flow: %s -> %s\n", d.ConnectionFlow.A, d.ConnectionFlow.B)
+	}
+
+	writeList(&sb, "priorities", d.Priorities)
+	writeList(&sb, "pseudo header order", d.PseudoHeaderOrder)
+
+	return sb.String()
+}
+
+// Empty returns true if the values do not differ.
+func (d ValueDiff) Empty() bool {
+	return d.A == d.B
+}
+
+// Empty returns true if the lists do not differ.
+func (d ListDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && !d.Reordered
+}
+
+func diffValue(name string, a string, b string) ValueDiff {
+	if a == b {
+		return ValueDiff{}
+	}
+
+	return ValueDiff{Name: name, A: a, B: b}
+}
+
+func diffValues(values []ValueDiff) []ValueDiff {
+	var diffs []ValueDiff
+	for _, value := range values {
+		if !value.Empty() {
+			diffs = append(diffs, value)
+		}
+	}
+
+	return diffs
+}
+
+func containsValueDiff(values []ValueDiff, name string) bool {
+	for _, value := range values {
+		if value.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diffLists compares the lists as multisets for the added and removed entries and the common entries for the order.
+func diffLists(a []string, b []string) ListDiff {
+	countA, countB := countStrings(a), countStrings(b)
+
+	diff := ListDiff{
+		Removed: missingEntries(a, countB),
+		Added:   missingEntries(b, countA),
+	}
+
+	commonA, commonB := commonEntries(a, countB), commonEntries(b, countA)
+	for i := range commonA {
+		if commonA[i] != commonB[i] {
+			diff.Reordered = true
+			break
+		}
+	}
+
+	if diff.Empty() {
+		return ListDiff{}
+	}
+
+	diff.A = append([]string(nil), a...)
+	diff.B = append([]string(nil), b...)
+
+	return diff
+}
+
+func countStrings(values []string) map[string]int {
+	counts := make(map[string]int, len(values))
+	for _, value := range values {
+		counts[value]++
+	}
+
+	return counts
+}
+
+// missingEntries returns the entries of values which are not counted in other, an entry listed twice in values and once in other is returned once.
+func missingEntries(values []string, other map[string]int) []string {
+	seen := make(map[string]int, len(values))
+
+	var missing []string
+	for _, value := range values {
+		seen[value]++
+		if seen[value] > other[value] {
+			missing = append(missing, value)
+		}
+	}
+
+	return missing
+}
+
+func commonEntries(values []string, other map[string]int) []string {
+	seen := make(map[string]int, len(values))
+
+	var common []string
+	for _, value := range values {
+		seen[value]++
+		if seen[value] <= other[value] {
+			common = append(common, value)
+		}
+	}
+
+	return common
+}
+
+func profileValueStrings(values []profileValue) []string {
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		strs = append(strs, string(value))
+	}
+
+	return strs
+}
+
+func extensionNames(extensions []profileExtension) []string {
+	names := make([]string, 0, len(extensions))
+	for _, extension := range extensions {
+		names = append(names, extension.Name)
+	}
+
+	return names
+}
+
+func keyShareStrings(extensions []profileExtension) []string {
+	var keyShares []string
+	for _, extension := range extensions {
+		for _, keyShare := range extension.KeyShares {
+			if keyShare.Data == "" {
+				keyShares = append(keyShares, string(keyShare.Group))
+			} else {
+				keyShares = append(keyShares, string(keyShare.Group)+":"+keyShare.Data)
+			}
+		}
+	}
+
+	return keyShares
+}
+
+// diffExtensionParameters compares the extensions with the same name, an extension listed twice is compared with the second one of the other profile.
+func diffExtensionParameters(a []profileExtension, b []profileExtension) ([]ExtensionDiff, error) {
+	var diffs []ExtensionDiff
+
+	seen := make(map[string]int, len(a))
+	for _, extensionA := range a {
+		occurrence := seen[extensionA.Name]
+		seen[extensionA.Name]++
+
+		extensionB, ok := nthExtension(b, extensionA.Name, occurrence)
+		if !ok {
+			continue
+		}
+
+		extensionA.KeyShares, extensionB.KeyShares = nil, nil
+
+		parametersA, err := extensionParameters(extensionA)
+		if err != nil {
+			return nil, err
+		}
+
+		parametersB, err := extensionParameters(extensionB)
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		for name := range parametersA {
+			names = append(names, name)
+		}
+
+		for name := range parametersB {
+			if _, ok := parametersA[name]; !ok {
+				names = append(names, name)
+			}
+		}
+
+		sort.Strings(names)
+
+		var parameters []ValueDiff
+		for _, name := range names {
+			parameters = append(parameters, ValueDiff{Name: name, A: parametersA[name], B: parametersB[name]})
+		}
+
+		if parameters = diffValues(parameters); len(parameters) > 0 {
+			diffs = append(diffs, ExtensionDiff{Name: extensionA.Name, Parameters: parameters})
+		}
+	}
+
+	return diffs, nil
+}
+
+func nthExtension(extensions []profileExtension, name string, n int) (profileExtension, bool) {
+	for _, extension := range extensions {
+		if extension.Name != name {
+			continue
+		}
+
+		if n == 0 {
+			return extension, true
+		}
+
+		n--
+	}
+
+	return profileExtension{}, false
+}
+
+// extensionParameters returns the parameters of the extension by their names in the profile format, with json encoded values.
+func extensionParameters(extension profileExtension) (map[string]string, error) {
+	encoded, err := json.Marshal(extension)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+
+	delete(fields, "name")
+
+	parameters := make(map[string]string, len(fields))
+	for name, value := range fields {
+		parameters[name] = string(value)
+	}
+
+	return parameters, nil
+}
+
+// sentSettings returns the values and the order of the settings the profile sends, by their names.
+func sentSettings(profile ClientProfile) (map[string]string, []string) {
+	settings, order := profile.settings, profile.settingsOrder
+	if settings == nil {
+		settings, order = DefaultH2Settings.copy()
+	}
+
+	values := make(map[string]string, len(order))
+	names := make([]string, 0, len(order))
+
+	// the transport sends the settings of the order only
+	for _, id := range order {
+		value, ok := settings[id]
+		if !ok {
+			continue
+		}
+
+		name := string(h2SettingNames.encode(uint16(id)))
+		values[name] = strconv.FormatUint(uint64(value), 10)
+		names = append(names, name)
+	}
+
+	return values, names
+}
+
+func priorityStrings(priorities []http2.Priority) []string {
+	strs := make([]string, 0, len(priorities))
+	for _, priority := range priorities {
+		exclusive := 0
+		if priority.PriorityParam.Exclusive {
+			exclusive = 1
+		}
+
+		strs = append(strs, fmt.Sprintf("%d:%d:%d:%d", priority.StreamID, exclusive, priority.PriorityParam.StreamDep, int(priority.PriorityParam.Weight)+1))
+	}
+
+	return strs
+}
+
+func writeValues(sb *strings.Builder, title string, values []ValueDiff) {
+	if len(values) == 0 {
+		return
+	}
+
+	sb.WriteString(title + ":\n")
+	for _, value := range values {
+		fmt.Fprintf(sb, "  %s: %s -> %s\n", value.Name, valueOrNone(value.A), valueOrNone(value.B))
+	}
+}
+
+func writeList(sb *strings.Builder, title string, list ListDiff) {
+	if list.Empty() {
+		return
+	}
+
+	sb.WriteString(title + ":\n")
+	for _, entry := range list.Removed {
+		sb.WriteString("  - " + entry + "\n")
+	}
+
+	for _, entry := range list.Added {
+		sb.WriteString("  + " + entry + "\n")
+	}
+
+	if list.Reordered {
+		fmt.Fprintf(sb, "  order: %s -> %s\n", strings.Join(list.A, ", "), strings.Join(list.B, ", "))
+	}
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+
+	return value
+}
@@ -0,0 +1,190 @@
+package tls_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+var (
+	// ErrProxyAuthRequired is matched by a ProxyConnectError with status 407, check it with errors.Is.
+	ErrProxyAuthRequired = errors.New("proxy authentication required")
+	// ErrInvalidURLScheme is returned for requests which are neither http nor https.
+	ErrInvalidURLScheme = errors.New("invalid URL scheme")
+	// ErrUnexpectedProtocol is returned when the server selects an application layer protocol which was not offered.
+	ErrUnexpectedProtocol = errors.New("unexpected application layer protocol")
+)
+
+// TimeoutPhase names the part of a request which timed out.
+type TimeoutPhase string
+
+const (
+	TimeoutPhaseDial           TimeoutPhase = "dial"
+	TimeoutPhaseProxyConnect   TimeoutPhase = "proxy connect"
+	TimeoutPhaseTLSHandshake   TimeoutPhase = "tls handshake"
+	TimeoutPhaseResponseHeader TimeoutPhase = "response header"
+	TimeoutPhaseRequest        TimeoutPhase = "request"
+)
+
+// TimeoutError is returned when a phase of the request did not finish in time.
+// It implements net.Error, so existing Timeout() checks keep working.
+type TimeoutError struct {
+	Phase TimeoutPhase
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout during %s: %v", e.Phase, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *TimeoutError) Temporary() bool {
+	return true
+}
+
+// DialError is returned when the connection to the destination or to the proxy could not be established.
+type DialError struct {
+	// Addr is the address which could not be reached, either the proxy or the destination.
+	Addr string
+	// Proxy is the redacted proxy url, empty for direct connections.
+	Proxy string
+	Err   error
+}
+
+func (e *DialError) Error() string {
+	if e.Proxy != "" {
+		return fmt.Sprintf("failed to dial %s via proxy %s: %v", e.Addr, e.Proxy, e.Err)
+	}
+
+	return fmt.Sprintf("failed to dial %s: %v", e.Addr, e.Err)
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+// ProxyConnectError is returned when the proxy did not answer the CONNECT request with 200.
+type ProxyConnectError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body holds the beginning of the response body of the proxy.
+	Body []byte
+	// Err is the reason why the request was not retried, e.g. the failed proxy authentication.
+	Err error
+}
+
+func (e *ProxyConnectError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Proxy responded with non 200 code: %s: %v", e.Status, e.Err)
+	}
+
+	return "Proxy responded with non 200 code: " + e.Status
+}
+
+func (e *ProxyConnectError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ProxyConnectError) Is(target error) bool {
+	return target == ErrProxyAuthRequired && e.StatusCode == http.StatusProxyAuthRequired
+}
+
+// TLSHandshakeError is returned when the tls handshake with the destination failed.
+type TLSHandshakeError struct {
+	ServerName string
+	// Alert is the tls alert sent by the server, HasAlert is false if the handshake failed for another reason.
+	Alert    uint8
+	HasAlert bool
+	Err      error
+}
+
+func (e *TLSHandshakeError) Error() string {
+	return fmt.Sprintf("tls handshake with %s failed: %v", e.ServerName, e.Err)
+}
+
+func (e *TLSHandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// newTLSHandshakeError wraps the error of the handshake. Only the timeout of the handshake phase reported by
+// handshakeWithTimeout is a TimeoutError, a request context which ended during the handshake is kept as the cause.
+func newTLSHandshakeError(ctx context.Context, serverName string, err error) error {
+	timeoutErr, handshakeTimedOut := err.(*TimeoutError)
+	if handshakeTimedOut {
+		err = timeoutErr.Err
+	} else if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+
+	handshakeErr := &TLSHandshakeError{ServerName: serverName, Err: err}
+
+	// utls reports alerts of the server as net.OpError with its unexported alert type
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" && opErr.Err != nil {
+		if v := reflect.ValueOf(opErr.Err); v.Kind() == reflect.Uint8 {
+			handshakeErr.Alert = uint8(v.Uint())
+			handshakeErr.HasAlert = true
+		}
+	}
+
+	if handshakeTimedOut {
+		return &TimeoutError{Phase: TimeoutPhaseTLSHandshake, Err: handshakeErr}
+	}
+
+	return handshakeErr
+}
+
+func newDialError(addr string, proxyUrl string, err error) error {
+	var dialErr *DialError
+	if errors.As(err, &dialErr) {
+		return err
+	}
+
+	dialErr = &DialError{Addr: addr, Proxy: proxyUrl, Err: err}
+
+	// keep the phase of a timeout which happened while dialing through a proxy
+	var timeoutErr *TimeoutError
+	if isTimeout(err) && !errors.As(err, &timeoutErr) {
+		return &TimeoutError{Phase: TimeoutPhaseDial, Err: dialErr}
+	}
+
+	return dialErr
+}
+
+// wrapRequestTimeout marks timeouts of the overall request which did not happen in a specific phase.
+func wrapRequestTimeout(err error) error {
+	var timeoutErr *TimeoutError
+	if !isTimeout(err) || errors.As(err, &timeoutErr) {
+		return err
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.Err = &TimeoutError{Phase: TimeoutPhaseRequest, Err: urlErr.Err}
+		return err
+	}
+
+	return &TimeoutError{Phase: TimeoutPhaseRequest, Err: err}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
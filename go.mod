@@ -7,7 +7,9 @@ require (
 	github.com/bogdanfinn/utls v1.5.9
 	github.com/google/uuid v1.3.0
 	github.com/stretchr/testify v1.8.0
+	golang.org/x/crypto v0.1.0
 	golang.org/x/net v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,10 +17,8 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/klauspost/compress v1.15.12 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/crypto v0.1.0 // indirect
 	golang.org/x/sys v0.1.0 // indirect
 	golang.org/x/text v0.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 // replace github.com/bogdanfinn/utls => ../utls
@@ -0,0 +1,61 @@
+package tls_client
+
+import (
+	"sort"
+
+	"github.com/bogdanfinn/fhttp/http2"
+)
+
+// H2Settings are the values of an http2 SETTINGS frame and the order in which they are sent.
+type H2Settings struct {
+	Settings map[http2.SettingID]uint32
+	// Order lists the settings in the order they are sent, settings missing in it are sent after it sorted by id.
+	Order []http2.SettingID
+}
+
+// DefaultH2Settings are sent by clients whose profile has no http2 settings, it can be overwritten before the clients are created.
+var DefaultH2Settings = H2Settings{
+	Settings: map[http2.SettingID]uint32{
+		http2.SettingHeaderTableSize:      65536,
+		http2.SettingMaxConcurrentStreams: 1000,
+		http2.SettingInitialWindowSize:    6291456,
+		http2.SettingMaxFrameSize:         16384,
+	},
+	Order: []http2.SettingID{
+		http2.SettingHeaderTableSize,
+		http2.SettingMaxConcurrentStreams,
+		http2.SettingInitialWindowSize,
+		http2.SettingMaxFrameSize,
+	},
+}
+
+// copy returns the settings and a complete order, both independent of the H2Settings.
+func (s H2Settings) copy() (map[http2.SettingID]uint32, []http2.SettingID) {
+	settings := make(map[http2.SettingID]uint32, len(s.Settings))
+	for id, value := range s.Settings {
+		settings[id] = value
+	}
+
+	order := make([]http2.SettingID, 0, len(settings))
+	ordered := make(map[http2.SettingID]bool, len(settings))
+
+	for _, id := range s.Order {
+		if _, ok := settings[id]; ok && !ordered[id] {
+			order = append(order, id)
+			ordered[id] = true
+		}
+	}
+
+	var rest []http2.SettingID
+	for id := range settings {
+		if !ordered[id] {
+			rest = append(rest, id)
+		}
+	}
+
+	sort.Slice(rest, func(i, j int) bool {
+		return rest[i] < rest[j]
+	})
+
+	return settings, append(order, rest...)
+}
@@ -0,0 +1,749 @@
+package tls_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/bogdanfinn/fhttp/http2/hpack"
+	"github.com/bogdanfinn/fhttp/httptrace"
+)
+
+// H2CMode selects whether http:// urls are spoken over HTTP/2 without tls (h2c).
+type H2CMode int
+
+const (
+	// H2CDisabled speaks HTTP/1.1 with http:// urls.
+	H2CDisabled H2CMode = iota
+	// H2CPriorKnowledge speaks HTTP/2 right away, the server has to support h2c.
+	H2CPriorKnowledge
+	// H2CUpgrade asks the server to switch to h2c with an HTTP/1.1 Upgrade request and stays at HTTP/1.1 if it does not.
+	H2CUpgrade
+)
+
+// h2cStreamMinRefresh is the number of body bytes of the upgrade response read before its window is updated.
+const h2cStreamMinRefresh = 4 << 10
+
+var errH2CBodyClosed = errors.New("http2: response body closed")
+
+// h2cTransport is the http2 transport of a http:// url, its connections are created by the pool.
+// With H2CUpgrade a request which finds no connection opens one by asking the server to upgrade,
+// after the server answered without switching the requests are sent with HTTP/1.1.
+type h2cTransport struct {
+	*http2.Transport
+	pool *h2cConnPool
+
+	// upgradeDial opens the connections which are upgraded, nil with prior knowledge
+	upgradeDial dialFunc
+	addr        string
+	proxyUrl    string
+	http1       http.RoundTripper
+
+	mu      sync.Mutex
+	refused bool
+}
+
+// newH2CTransport lets t2 send http:// urls over connections created by the pool.
+func newH2CTransport(t2 *http2.Transport, pool *h2cConnPool) *h2cTransport {
+	// the http2 transport takes http:// urls only with AllowHTTP
+	t2.AllowHTTP = true
+	t2.ConnPool = pool
+
+	return &h2cTransport{Transport: t2, pool: pool}
+}
+
+// newH2CUpgradeTransport is a h2cTransport which opens connections with an upgrade of the request and falls back to http1.
+func newH2CUpgradeTransport(t2 *http2.Transport, pool *h2cConnPool, addr string, dial dialFunc, proxyUrl string, http1 http.RoundTripper) *h2cTransport {
+	t := newH2CTransport(t2, pool)
+	t.upgradeDial = dial
+	t.addr = addr
+	t.proxyUrl = proxyUrl
+	t.http1 = http1
+
+	return t
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.upgradeDial == nil || t.pool.hasConn() {
+		return t.Transport.RoundTrip(req)
+	}
+
+	t.mu.Lock()
+	refused := t.refused
+	t.mu.Unlock()
+
+	if refused {
+		return t.http1.RoundTrip(req)
+	}
+
+	return t.roundTripUpgrade(req)
+}
+
+// CloseIdleConnections closes the idle connections of the pool, busy ones are closed once their requests are finished.
+func (t *h2cTransport) CloseIdleConnections() {
+	t.pool.closeConnections()
+
+	if closer, ok := t.http1.(idleConnectionsCloser); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// roundTripUpgrade sends the request with HTTP/1.1 on a new connection and asks the server to switch it to h2c.
+// A server which switches answers the request on stream 1 of the connection, otherwise with HTTP/1.1.
+func (t *h2cTransport) roundTripUpgrade(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	rawConn, err := t.upgradeDial(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	upgradeReq := req.Clone(ctx)
+	upgradeReq.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	upgradeReq.Header.Set("Upgrade", "h2c")
+	upgradeReq.Header.Set("HTTP2-Settings", encodeH2CSettings(t.Transport))
+
+	// like the http2 transport does for the following requests
+	requestedGzip := t.requestGzip(req)
+	if requestedGzip {
+		upgradeReq.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	}
+
+	reader := bufio.NewReader(rawConn)
+
+	resp, err := t.sendUpgradeRequest(upgradeReq, rawConn, reader)
+	if err != nil {
+		_ = rawConn.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.mu.Lock()
+		t.refused = true
+		t.mu.Unlock()
+
+		traceGotConn(req, newInfoConn(rawConn, nil, t.proxyUrl, clientTraceFrom(ctx)))
+
+		resp.Request = req
+		resp.Body = &connClosingBody{ReadCloser: resp.Body, conn: rawConn}
+		if requestedGzip {
+			resp.Body = http.DecompressBody(resp)
+		}
+
+		return resp, nil
+	}
+
+	upgraded := newH2CUpgradedConn(rawConn, reader, t.headerTableSize())
+	conn := newInfoConn(upgraded, nil, t.proxyUrl, clientTraceFrom(ctx))
+
+	// with AllowHTTP the connection starts with stream 3
+	cc, err := t.Transport.NewClientConn(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	t.pool.addConn(cc)
+	traceGotConn(req, conn)
+
+	return upgraded.awaitResponse(req, t.GetT1().ResponseHeaderTimeout)
+}
+
+// sendUpgradeRequest writes the upgrade request and reads the HTTP/1.1 response of the server.
+func (t *h2cTransport) sendUpgradeRequest(req *http.Request, conn net.Conn, reader *bufio.Reader) (*http.Response, error) {
+	ctx := req.Context()
+
+	stopWatching := interruptOnDone(ctx, conn)
+	defer stopWatching()
+
+	if err := req.Write(conn); err != nil {
+		return nil, h2cUpgradeError(ctx, err)
+	}
+
+	if timeout := t.GetT1().ResponseHeaderTimeout; timeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, h2cUpgradeError(ctx, err)
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+
+	return resp, nil
+}
+
+// requestGzip reports whether the http2 transport would ask for a compressed response, see ClientConn.requestGzip.
+func (t *h2cTransport) requestGzip(req *http.Request) bool {
+	t1 := t.GetT1()
+
+	return (t1 == nil || !t1.DisableCompression) &&
+		req.Header.Get("Accept-Encoding") == "" &&
+		req.Header.Get("Range") == "" &&
+		req.Method != http.MethodHead
+}
+
+// headerTableSize is the size of the header table the client announces, which the server uses to encode its headers.
+func (t *h2cTransport) headerTableSize() uint32 {
+	if size, ok := t.Settings[http2.SettingHeaderTableSize]; ok {
+		return size
+	}
+
+	return 4096
+}
+
+func h2cUpgradeError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return fmt.Errorf("h2c upgrade failed: %w", err)
+}
+
+// traceGotConn runs the GotConn hook for a connection which was not picked by the transport.
+func traceGotConn(req *http.Request, conn net.Conn) {
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn})
+	}
+}
+
+// h2cConnPool holds the connections of a h2cTransport.
+// AllowHTTP lets the http2 transport start every connection with stream 3, as stream 1 belongs to an upgrade request,
+// so connections with prior knowledge are created by a transport without AllowHTTP and start with stream 1.
+type h2cConnPool struct {
+	t2   *http2.Transport
+	dial dialFunc
+
+	mu    sync.Mutex
+	conns []*http2.ClientConn
+}
+
+// newH2CConnPool creates the connections with t2, which must be configured like the transport using the pool.
+func newH2CConnPool(t2 *http2.Transport, dial dialFunc) *h2cConnPool {
+	p := &h2cConnPool{t2: t2, dial: dial}
+	t2.ConnPool = p
+
+	return p
+}
+
+func (p *h2cConnPool) GetClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	p.mu.Lock()
+	for _, cc := range p.conns {
+		if cc.CanTakeNewRequest() {
+			p.mu.Unlock()
+			return cc, nil
+		}
+	}
+	p.mu.Unlock()
+
+	conn, err := p.dial(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := p.t2.NewClientConn(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	p.addConn(cc)
+
+	return cc, nil
+}
+
+func (p *h2cConnPool) hasConn() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cc := range p.conns {
+		if cc.CanTakeNewRequest() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *h2cConnPool) addConn(cc *http2.ClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.conns = append(p.conns, cc)
+}
+
+func (p *h2cConnPool) MarkDead(cc *http2.ClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, c := range p.conns {
+		if c == cc {
+			p.conns = append(p.conns[:i], p.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+// closeConnections shuts the connections down gracefully, the ClientConn does not tell whether it is idle.
+func (p *h2cConnPool) closeConnections() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = nil
+	p.mu.Unlock()
+
+	for _, cc := range conns {
+		go func(cc *http2.ClientConn) {
+			_ = cc.Shutdown(context.Background())
+		}(cc)
+	}
+}
+
+// h2cUpgradedConn is a connection switched to h2c by an upgrade request, which the server answers on stream 1.
+// The http2 transport ignores stream 1 as it did not open it, so the frames of the server are read here
+// and passed on to the transport until the response is complete. The DATA of stream 1 is kept from the transport,
+// which would add it to its connection window without having taken it, so its flow control is done here as well.
+type h2cUpgradedConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	pipeReader *io.PipeReader
+	pipeWriter *io.PipeWriter
+	writer     *h2cFrameWriter
+
+	response chan h2cUpgradeResult
+	finished chan struct{}
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	gotHeaders bool
+	ended      bool
+	body       bytes.Buffer
+	// bodyErr is io.EOF once the server sent the whole response
+	bodyErr error
+	// unacked counts the body bytes read or dropped since the last WINDOW_UPDATE
+	unacked int
+}
+
+type h2cUpgradeResult struct {
+	headers *http2.MetaHeadersFrame
+	err     error
+}
+
+func newH2CUpgradedConn(conn net.Conn, reader *bufio.Reader, headerTableSize uint32) *h2cUpgradedConn {
+	pipeReader, pipeWriter := io.Pipe()
+
+	c := &h2cUpgradedConn{
+		Conn:       conn,
+		reader:     reader,
+		pipeReader: pipeReader,
+		pipeWriter: pipeWriter,
+		writer:     &h2cFrameWriter{conn: conn, remaining: len(http2.ClientPreface)},
+		response:   make(chan h2cUpgradeResult, 1),
+		finished:   make(chan struct{}),
+	}
+	c.cond = sync.NewCond(&c.mu)
+
+	go c.readStream(headerTableSize)
+
+	return c
+}
+
+func (c *h2cUpgradedConn) Read(p []byte) (int, error) {
+	n, err := c.pipeReader.Read(p)
+	if err == io.EOF {
+		// the response is complete, the transport reads on its own
+		return c.reader.Read(p)
+	}
+
+	return n, err
+}
+
+func (c *h2cUpgradedConn) Write(p []byte) (int, error) {
+	return c.writer.Write(p)
+}
+
+func (c *h2cUpgradedConn) Close() error {
+	// stops readStream from waiting for the transport
+	_ = c.pipeReader.Close()
+
+	return c.Conn.Close()
+}
+
+// readStream reads the frames of the server until stream 1 is done and passes them on to the transport.
+// The header blocks of all streams are decoded to keep the header table in sync with the one of the server.
+func (c *h2cUpgradedConn) readStream(headerTableSize uint32) {
+	var frameBytes bytes.Buffer
+
+	framer := http2.NewFramer(ioutil.Discard, io.TeeReader(c.reader, &frameBytes))
+	framer.ReadMetaHeaders = hpack.NewDecoder(headerTableSize, nil)
+
+	for !c.isEnded() {
+		frameBytes.Reset()
+
+		frame, err := framer.ReadFrame()
+		if data, ok := frame.(*http2.DataFrame); !ok || data.StreamID != 1 {
+			if _, writeErr := c.pipeWriter.Write(frameBytes.Bytes()); writeErr != nil {
+				c.end(writeErr, false)
+				return
+			}
+		}
+
+		if err != nil {
+			var streamErr http2.StreamError
+			if errors.As(err, &streamErr) && streamErr.StreamID != 1 {
+				continue
+			}
+
+			_ = c.pipeWriter.CloseWithError(err)
+
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			c.end(err, false)
+
+			return
+		}
+
+		c.handleFrame(frame)
+	}
+
+	_ = c.pipeWriter.Close()
+}
+
+func (c *h2cUpgradedConn) handleFrame(frame http2.Frame) {
+	switch f := frame.(type) {
+	case *http2.MetaHeadersFrame:
+		if f.StreamID != 1 {
+			return
+		}
+
+		c.mu.Lock()
+		// informational responses like 100 Continue are skipped, later headers are trailers
+		if !c.gotHeaders && !strings.HasPrefix(f.PseudoValue("status"), "1") {
+			c.gotHeaders = true
+			c.response <- h2cUpgradeResult{headers: f}
+		}
+		c.mu.Unlock()
+
+		if f.StreamEnded() {
+			c.end(io.EOF, false)
+		}
+	case *http2.DataFrame:
+		if f.StreamID != 1 {
+			return
+		}
+
+		c.mu.Lock()
+		gotHeaders := c.gotHeaders
+		if gotHeaders && !c.ended {
+			c.body.Write(f.Data())
+			// the padding is not read by anyone
+			c.unacked += int(f.Length) - len(f.Data())
+			c.cond.Broadcast()
+		} else {
+			c.unacked += int(f.Length)
+		}
+		c.refreshWindow(false)
+		c.mu.Unlock()
+
+		if !gotHeaders {
+			c.end(errors.New("http2: received DATA before HEADERS on stream 1"), true)
+		} else if f.StreamEnded() {
+			c.end(io.EOF, false)
+		}
+	case *http2.RSTStreamFrame:
+		if f.StreamID == 1 {
+			c.end(http2.StreamError{StreamID: 1, Code: f.ErrCode}, false)
+		}
+	case *http2.GoAwayFrame:
+		if f.LastStreamID == 0 {
+			c.end(errors.New("http2: server sent GOAWAY without answering the upgrade request"), false)
+		}
+	}
+}
+
+func (c *h2cUpgradedConn) isEnded() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ended
+}
+
+// end finishes stream 1 with err, which is io.EOF if the response is complete. reset cancels the stream at the server.
+func (c *h2cUpgradedConn) end(err error, reset bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ended {
+		return
+	}
+
+	c.ended = true
+	c.bodyErr = err
+
+	if !c.gotHeaders {
+		c.gotHeaders = true
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		c.response <- h2cUpgradeResult{err: err}
+	}
+
+	if reset {
+		c.writer.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteRSTStream(1, http2.ErrCodeCancel)
+		})
+	}
+
+	close(c.finished)
+	c.cond.Broadcast()
+}
+
+// awaitResponse returns the response of the server on stream 1.
+func (c *h2cUpgradedConn) awaitResponse(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	ctx := req.Context()
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	var result h2cUpgradeResult
+	select {
+	case result = <-c.response:
+	case <-ctx.Done():
+		c.end(ctx.Err(), true)
+		return nil, ctx.Err()
+	case <-timer:
+		err := &TimeoutError{Phase: TimeoutPhaseResponseHeader, Err: errors.New("timeout awaiting response headers")}
+		c.end(err, true)
+		return nil, err
+	}
+
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	status := result.headers.PseudoValue("status")
+	statusCode, err := strconv.Atoi(status)
+	if err != nil {
+		c.end(err, true)
+		return nil, errors.New("malformed response from server: malformed non-numeric status pseudo header")
+	}
+
+	header := make(http.Header)
+	for _, field := range result.headers.RegularFields() {
+		header.Add(http.CanonicalHeaderKey(field.Name), field.Value)
+	}
+
+	resp := &http.Response{
+		Status:        status + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        header,
+		ContentLength: -1,
+		Request:       req,
+		Body:          &h2cUpgradeBody{conn: c},
+	}
+
+	if contentLength, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		resp.ContentLength = contentLength
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.end(ctx.Err(), true)
+		case <-c.finished:
+		}
+	}()
+
+	// like the http2 transport does
+	resp.Body = http.DecompressBody(resp)
+
+	return resp, nil
+}
+
+// h2cUpgradeBody is the body of the response on stream 1.
+type h2cUpgradeBody struct {
+	conn *h2cUpgradedConn
+}
+
+func (b *h2cUpgradeBody) Read(p []byte) (int, error) {
+	c := b.conn
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.body.Len() == 0 && !c.ended {
+		c.cond.Wait()
+	}
+
+	if c.body.Len() == 0 {
+		return 0, c.bodyErr
+	}
+
+	n, _ := c.body.Read(p)
+
+	c.unacked += n
+	c.refreshWindow(false)
+
+	return n, nil
+}
+
+func (b *h2cUpgradeBody) Close() error {
+	c := b.conn
+	c.end(errH2CBodyClosed, true)
+
+	c.mu.Lock()
+	c.unacked += c.body.Len()
+	c.refreshWindow(true)
+	c.body.Reset()
+	c.bodyErr = errH2CBodyClosed
+	c.mu.Unlock()
+
+	return nil
+}
+
+// refreshWindow gives the server the window of the unacked bytes back, the one of stream 1 only while it is open.
+// Without force small increments are collected first. It is called with mu held.
+func (c *h2cUpgradedConn) refreshWindow(force bool) {
+	if c.unacked == 0 || (!force && c.unacked < h2cStreamMinRefresh) {
+		return
+	}
+
+	increment := uint32(c.unacked)
+	streamOpen := !c.ended
+	c.unacked = 0
+
+	c.writer.writeFrame(func(fr *http2.Framer) error {
+		if err := fr.WriteWindowUpdate(0, increment); err != nil || !streamOpen {
+			return err
+		}
+
+		return fr.WriteWindowUpdate(1, increment)
+	})
+}
+
+// h2cFrameWriter writes the frames of the transport and lets frames of its own be written in between.
+type h2cFrameWriter struct {
+	conn net.Conn
+
+	mu sync.Mutex
+	// remaining is the number of bytes left of the preface or of the frame being written
+	remaining int
+	header    []byte
+	pending   []byte
+}
+
+func (w *h2cFrameWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, err := w.conn.Write(p)
+	w.advance(p[:n])
+
+	if err == nil {
+		w.flushPending()
+	}
+
+	return n, err
+}
+
+// writeFrame writes the frame as soon as the transport is not in the middle of a frame.
+func (w *h2cFrameWriter) writeFrame(write func(fr *http2.Framer) error) {
+	var frame bytes.Buffer
+	_ = write(http2.NewFramer(&frame, nil))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, frame.Bytes()...)
+	w.flushPending()
+}
+
+func (w *h2cFrameWriter) flushPending() {
+	if len(w.pending) == 0 || w.remaining > 0 || len(w.header) > 0 {
+		return
+	}
+
+	_, _ = w.conn.Write(w.pending)
+	w.pending = nil
+}
+
+// advance follows the frames the transport writes, which may be split over several writes.
+func (w *h2cFrameWriter) advance(p []byte) {
+	for len(p) > 0 {
+		if w.remaining > 0 {
+			n := w.remaining
+			if n > len(p) {
+				n = len(p)
+			}
+
+			w.remaining -= n
+			p = p[n:]
+			continue
+		}
+
+		n := 9 - len(w.header)
+		if n > len(p) {
+			n = len(p)
+		}
+
+		w.header = append(w.header, p[:n]...)
+		p = p[n:]
+
+		if len(w.header) == 9 {
+			w.remaining = frameLength(w.header)
+			w.header = w.header[:0]
+		}
+	}
+}
+
+// encodeH2CSettings encodes the SETTINGS of the transport in the order of the profile for the HTTP2-Settings header.
+func encodeH2CSettings(t2 *http2.Transport) string {
+	payload := make([]byte, 0, 6*len(t2.SettingsOrder))
+
+	for _, id := range t2.SettingsOrder {
+		var setting [6]byte
+		binary.BigEndian.PutUint16(setting[:2], uint16(id))
+		binary.BigEndian.PutUint32(setting[2:], t2.Settings[id])
+
+		payload = append(payload, setting[:]...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// connClosingBody closes the connection of a response which is not kept for further requests.
+type connClosingBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b *connClosingBody) Close() error {
+	err := b.ReadCloser.Close()
+	_ = b.conn.Close()
+
+	return err
+}
@@ -1,8 +1,11 @@
 package tls_client
 
 import (
+	"crypto/md5"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 
@@ -187,3 +190,110 @@ func getExtensionBaseMap() map[uint16]tls.TLSExtension {
 		},
 	}
 }
+
+// JA3 returns the JA3 string of the ClientHello the profile sends. GREASE values are left out like common JA3 tools do,
+// the server name and padding extensions are always included. The extensions are in the order of the ClientHelloSpec,
+// with WithRandomTLSExtensionOrder every connection sends them in another order and therefore has another JA3.
+func (c ClientProfile) JA3() (string, error) {
+	spec, err := clientHelloSpec(c.clientHelloId)
+	if err != nil {
+		return "", err
+	}
+
+	return specToJa3(spec), nil
+}
+
+// JA3Hash returns the md5 hash of the JA3 string of the profile.
+func (c ClientProfile) JA3Hash() (string, error) {
+	ja3, err := c.JA3()
+	if err != nil {
+		return "", err
+	}
+
+	hash := md5.Sum([]byte(ja3))
+
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func specToJa3(spec tls.ClientHelloSpec) string {
+	// the version of the ClientHello is capped at tls 1.2, newer versions are offered with the supported_versions extension
+	version := spec.TLSVersMax
+	if version == 0 || version > tls.VersionTLS12 {
+		version = tls.VersionTLS12
+	}
+
+	var ciphers, extensions, curves, pointFormats []string
+
+	for _, cipher := range spec.CipherSuites {
+		if !isGREASE(cipher) {
+			ciphers = append(ciphers, strconv.Itoa(int(cipher)))
+		}
+	}
+
+	for _, id := range specExtensionIds(spec) {
+		extensions = append(extensions, strconv.Itoa(int(id)))
+	}
+
+	for _, extension := range spec.Extensions {
+		switch ext := extension.(type) {
+		case *tls.SupportedCurvesExtension:
+			for _, curve := range ext.Curves {
+				if !isGREASE(uint16(curve)) {
+					curves = append(curves, strconv.Itoa(int(curve)))
+				}
+			}
+		case *tls.SupportedPointsExtension:
+			for _, pointFormat := range ext.SupportedPoints {
+				pointFormats = append(pointFormats, strconv.Itoa(int(pointFormat)))
+			}
+		}
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(int(version)),
+		strings.Join(ciphers, "-"),
+		strings.Join(extensions, "-"),
+		strings.Join(curves, "-"),
+		strings.Join(pointFormats, "-"),
+	}, ",")
+}
+
+// specExtensionIds returns the ids of the extensions of the spec without GREASE, the server name and padding extensions are always included.
+func specExtensionIds(spec tls.ClientHelloSpec) []uint16 {
+	var ids []uint16
+
+	for _, extension := range spec.Extensions {
+		switch extension.(type) {
+		case *tls.UtlsGREASEExtension:
+			continue
+		case *tls.SNIExtension:
+			// written once the connection knows the server name
+			ids = append(ids, tls.ExtensionServerName)
+			continue
+		case *tls.UtlsPaddingExtension:
+			// written depending on the length of the ClientHello
+			ids = append(ids, tls.ExtensionPadding)
+			continue
+		}
+
+		if id, ok := extensionId(extension); ok && !isGREASE(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// extensionId returns the id utls writes for the extension, false if it writes nothing.
+func extensionId(extension tls.TLSExtension) (uint16, bool) {
+	written := make([]byte, extension.Len())
+	if _, err := extension.Read(written); err != nil && err != io.EOF {
+		return 0, false
+	}
+
+	if len(written) < 4 {
+		return 0, false
+	}
+
+	return uint16(written[0])<<8 | uint16(written[1]), true
+}
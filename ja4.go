@@ -0,0 +1,463 @@
+package tls_client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	http "github.com/bogdanfinn/fhttp"
+	tls "github.com/bogdanfinn/utls"
+)
+
+var ja4Versions = map[uint16]string{
+	tls.VersionTLS13: "13",
+	tls.VersionTLS12: "12",
+	tls.VersionTLS11: "11",
+	tls.VersionTLS10: "10",
+	tls.VersionSSL30: "s3",
+}
+
+// ja4Fields are the values of a ClientHello JA4 is computed from.
+type ja4Fields struct {
+	prefix string
+	// ciphers, extensions and signatureAlgorithms are 4 digit hex values in the order of the ClientHello
+	ciphers             []string
+	extensions          []string
+	signatureAlgorithms []string
+}
+
+// JA4 returns the JA4 fingerprint of the ClientHello the profile sends, like "t13d1516h2_" followed by the hashes of the
+// cipher suites and of the extensions and signature algorithms. Like JA3 it assumes the server name and padding extensions are sent.
+func (c ClientProfile) JA4() (string, error) {
+	fields, err := c.ja4Fields()
+	if err != nil {
+		return "", err
+	}
+
+	return fields.hashed(), nil
+}
+
+// JA4Raw returns the JA4 fingerprint of the profile with the sorted values instead of their hashes (JA4_r).
+func (c ClientProfile) JA4Raw() (string, error) {
+	fields, err := c.ja4Fields()
+	if err != nil {
+		return "", err
+	}
+
+	return fields.raw(false), nil
+}
+
+// JA4RawOriginal returns the JA4 fingerprint of the profile with the values in the order of the ClientHello (JA4_ro).
+func (c ClientProfile) JA4RawOriginal() (string, error) {
+	fields, err := c.ja4Fields()
+	if err != nil {
+		return "", err
+	}
+
+	return fields.raw(true), nil
+}
+
+// MatchesJA4 reports whether the profile has the JA4 fingerprint, which can be given hashed, raw or raw in original order.
+func (c ClientProfile) MatchesJA4(fingerprint string) (bool, error) {
+	expected, err := parseJA4(fingerprint)
+	if err != nil {
+		return false, err
+	}
+
+	fields, err := c.ja4Fields()
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case expected.hashedOnly:
+		return fields.hashed() == strings.TrimSpace(fingerprint), nil
+	case expected.original:
+		return fields.raw(true) == expected.fields.raw(true), nil
+	default:
+		return fields.raw(false) == expected.fields.raw(false), nil
+	}
+}
+
+// HashJA4 returns the hashed form of a JA4 fingerprint given hashed, raw or raw in original order.
+func HashJA4(fingerprint string) (string, error) {
+	parsed, err := parseJA4(fingerprint)
+	if err != nil {
+		return "", err
+	}
+
+	if parsed.hashedOnly {
+		return strings.TrimSpace(fingerprint), nil
+	}
+
+	return parsed.fields.hashed(), nil
+}
+
+func (c ClientProfile) ja4Fields() (ja4Fields, error) {
+	spec, err := clientHelloSpec(c.clientHelloId)
+	if err != nil {
+		return ja4Fields{}, err
+	}
+
+	return specToJa4Fields(spec), nil
+}
+
+func specToJa4Fields(spec tls.ClientHelloSpec) ja4Fields {
+	var fields ja4Fields
+
+	version := spec.TLSVersMax
+	serverName := "i"
+	alpn := "00"
+
+	for _, cipher := range spec.CipherSuites {
+		if !isGREASE(cipher) {
+			fields.ciphers = append(fields.ciphers, fmt.Sprintf("%04x", cipher))
+		}
+	}
+
+	for _, id := range specExtensionIds(spec) {
+		fields.extensions = append(fields.extensions, fmt.Sprintf("%04x", id))
+	}
+
+	for _, extension := range spec.Extensions {
+		switch ext := extension.(type) {
+		case *tls.SNIExtension:
+			serverName = "d"
+		case *tls.ALPNExtension:
+			if len(ext.AlpnProtocols) > 0 {
+				alpn = ja4ALPN(ext.AlpnProtocols[0])
+			}
+		case *tls.SupportedVersionsExtension:
+			version = 0
+			for _, supported := range ext.Versions {
+				if !isGREASE(supported) && supported > version {
+					version = supported
+				}
+			}
+		case *tls.SignatureAlgorithmsExtension:
+			for _, algorithm := range ext.SupportedSignatureAlgorithms {
+				if !isGREASE(uint16(algorithm)) {
+					fields.signatureAlgorithms = append(fields.signatureAlgorithms, fmt.Sprintf("%04x", uint16(algorithm)))
+				}
+			}
+		}
+	}
+
+	versionName, ok := ja4Versions[version]
+	if !ok {
+		versionName = "00"
+	}
+
+	fields.prefix = fmt.Sprintf("t%s%s%02d%02d%s", versionName, serverName, min99(len(fields.ciphers)), min99(len(fields.extensions)), alpn)
+
+	return fields
+}
+
+// ja4ALPN returns the first and the last character of the protocol, or of its hex encoding if they are not alphanumeric.
+func ja4ALPN(protocol string) string {
+	if protocol == "" {
+		return "00"
+	}
+
+	first, last := protocol[0], protocol[len(protocol)-1]
+	if isAlphanumeric(first) && isAlphanumeric(last) {
+		return string([]byte{first, last})
+	}
+
+	encoded := hex.EncodeToString([]byte(protocol))
+
+	return string([]byte{encoded[0], encoded[len(encoded)-1]})
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func min99(n int) int {
+	if n > 99 {
+		return 99
+	}
+
+	return n
+}
+
+// raw returns the JA4_r fingerprint, with original the JA4_ro one.
+func (f ja4Fields) raw(original bool) string {
+	ciphers, extensions := f.ciphers, f.extensions
+	if !original {
+		ciphers, extensions = sortedJa4Ciphers(f.ciphers), sortedJa4Extensions(f.extensions)
+	}
+
+	parts := []string{f.prefix, strings.Join(ciphers, ","), strings.Join(extensions, ",")}
+	if len(f.signatureAlgorithms) > 0 {
+		parts = append(parts, strings.Join(f.signatureAlgorithms, ","))
+	}
+
+	return strings.Join(parts, "_")
+}
+
+func (f ja4Fields) hashed() string {
+	extensions := strings.Join(sortedJa4Extensions(f.extensions), ",")
+	if len(f.signatureAlgorithms) > 0 {
+		extensions += "_" + strings.Join(f.signatureAlgorithms, ",")
+	}
+
+	ciphers := strings.Join(sortedJa4Ciphers(f.ciphers), ",")
+
+	return strings.Join([]string{f.prefix, ja4Hash(ciphers), ja4Hash(extensions)}, "_")
+}
+
+func sortedJa4Ciphers(ciphers []string) []string {
+	sorted := append([]string(nil), ciphers...)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// sortedJa4Extensions sorts the extensions without the server name and alpn extensions.
+func sortedJa4Extensions(extensions []string) []string {
+	var sorted []string
+	for _, extension := range extensions {
+		if extension != "0000" && extension != "0010" {
+			sorted = append(sorted, extension)
+		}
+	}
+
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// ja4Hash returns the first 12 hex characters of the sha256 hash of the value, zeros for an empty value.
+func ja4Hash(value string) string {
+	if value == "" {
+		return "000000000000"
+	}
+
+	hash := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(hash[:])[:12]
+}
+
+type parsedJA4 struct {
+	fields     ja4Fields
+	hashedOnly bool
+	original   bool
+}
+
+// parseJA4 parses a hashed, raw or raw original JA4 fingerprint. A raw fingerprint counts as original
+// if its extensions contain the server name or alpn extension or are not sorted.
+func parseJA4(fingerprint string) (parsedJA4, error) {
+	parts := strings.Split(strings.TrimSpace(fingerprint), "_")
+	if len(parts) < 3 || len(parts) > 4 || len(parts[0]) != 10 || !strings.ContainsAny(parts[0][:1], "tqd") {
+		return parsedJA4{}, fmt.Errorf("%q is no JA4 fingerprint", fingerprint)
+	}
+
+	if len(parts) == 3 && len(parts[1]) == 12 && len(parts[2]) == 12 && isHex(parts[1]) && isHex(parts[2]) {
+		return parsedJA4{hashedOnly: true}, nil
+	}
+
+	fields := ja4Fields{prefix: parts[0]}
+	lists := []*[]string{&fields.ciphers, &fields.extensions, &fields.signatureAlgorithms}
+
+	for i, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+
+		for _, value := range strings.Split(part, ",") {
+			if len(value) != 4 || !isHex(value) {
+				return parsedJA4{}, fmt.Errorf("invalid value %q of JA4 fingerprint %q", value, fingerprint)
+			}
+
+			*lists[i] = append(*lists[i], strings.ToLower(value))
+		}
+	}
+
+	original := !sort.StringsAreSorted(fields.ciphers) || !sort.StringsAreSorted(fields.extensions)
+	for _, extension := range fields.extensions {
+		if extension == "0000" || extension == "0010" {
+			original = true
+		}
+	}
+
+	return parsedJA4{fields: fields, original: original}, nil
+}
+
+func isHex(value string) bool {
+	_, err := hex.DecodeString(value)
+
+	return err == nil && len(value)%2 == 0
+}
+
+// JA4H returns the JA4H fingerprint of the request sent with the profile, like "ge20cr05enus_" followed by
+// the hashes of the header names, the cookie names and the cookies. The http version is 20 if the profile offers h2, 11 otherwise.
+// The headers are taken in the order the client sends them, headers added by the transport and cookies of the cookie jar are not included.
+func (c ClientProfile) JA4H(req *http.Request) string {
+	http2 := c.offersHttp2()
+
+	return ja4hFromFields(ja4hRequestFields(req, http2), http2, false)
+}
+
+// JA4HRaw returns the JA4H fingerprint of the request with the header and cookie names and the cookies instead of their hashes (JA4H_r).
+func (c ClientProfile) JA4HRaw(req *http.Request) string {
+	http2 := c.offersHttp2()
+
+	return ja4hFromFields(ja4hRequestFields(req, http2), http2, true)
+}
+
+// offersHttp2 reports whether the client hello of the profile offers h2 with its alpn extension.
+// Profiles whose client hello can not be built are expected to speak http2, as they come with http2 settings.
+func (c ClientProfile) offersHttp2() bool {
+	spec, err := clientHelloSpec(c.clientHelloId)
+	if err != nil {
+		return true
+	}
+
+	for _, extension := range spec.Extensions {
+		if alpn, ok := extension.(*tls.ALPNExtension); ok {
+			for _, protocol := range alpn.AlpnProtocols {
+				if protocol == "h2" {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// HashJA4H returns the hashed form of a JA4H fingerprint given hashed or raw.
+func HashJA4H(fingerprint string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(fingerprint), "_")
+	if len(parts) != 4 || len(parts[0]) != 12 {
+		return "", fmt.Errorf("%q is no JA4H fingerprint", fingerprint)
+	}
+
+	if len(parts[1]) == 12 && isHex(parts[1]) && len(parts[2]) == 12 && isHex(parts[2]) && len(parts[3]) == 12 && isHex(parts[3]) {
+		return strings.TrimSpace(fingerprint), nil
+	}
+
+	return strings.Join([]string{parts[0], ja4Hash(parts[1]), ja4Hash(parts[2]), ja4Hash(parts[3])}, "_"), nil
+}
+
+type ja4hFields struct {
+	method         string
+	headers        []string
+	cookies        []string
+	referer        bool
+	acceptLanguage string
+}
+
+func ja4hRequestFields(req *http.Request, http2 bool) ja4hFields {
+	fields := ja4hFields{method: req.Method}
+	if fields.method == "" {
+		fields.method = http.MethodGet
+	}
+
+	header := req.Header.Clone()
+	delete(header, http.PHeaderOrderKey)
+
+	var kvs []http.HeaderKeyValues
+	if headerOrder, ok := header[http.HeaderOrderKey]; ok {
+		delete(header, http.HeaderOrderKey)
+
+		order := make(map[string]int)
+		for i, name := range headerOrder {
+			order[name] = i
+		}
+
+		kvs, _ = header.SortedKeyValuesBy(order, make(map[string]bool))
+	} else {
+		kvs, _ = header.SortedKeyValues(make(map[string]bool))
+	}
+
+	for _, kv := range kvs {
+		name := strings.ToLower(kv.Key)
+
+		switch name {
+		case "host", "connection", "proxy-connection", "transfer-encoding", "upgrade", "keep-alive":
+			// not sent over http2
+			if http2 {
+				continue
+			}
+		case "cookie":
+			for _, value := range kv.Values {
+				for _, cookie := range strings.Split(value, ";") {
+					if cookie = strings.TrimSpace(cookie); cookie != "" {
+						fields.cookies = append(fields.cookies, cookie)
+					}
+				}
+			}
+			continue
+		case "referer":
+			fields.referer = true
+			continue
+		case "accept-language":
+			if len(kv.Values) > 0 {
+				fields.acceptLanguage = kv.Values[0]
+			}
+		}
+
+		fields.headers = append(fields.headers, name)
+	}
+
+	return fields
+}
+
+func ja4hFromFields(fields ja4hFields, http2 bool, raw bool) string {
+	method := strings.ToLower(fields.method)
+	if len(method) > 2 {
+		method = method[:2]
+	}
+
+	cookie, referer := "n", "n"
+	if len(fields.cookies) > 0 {
+		cookie = "c"
+	}
+
+	if fields.referer {
+		referer = "r"
+	}
+
+	language := strings.ToLower(strings.ReplaceAll(strings.Split(fields.acceptLanguage, ",")[0], "-", ""))
+	language = strings.Split(language, ";")[0]
+	if len(language) > 4 {
+		language = language[:4]
+	}
+
+	language += strings.Repeat("0", 4-len(language))
+
+	var cookieNames []string
+	cookies := append([]string(nil), fields.cookies...)
+	sort.Strings(cookies)
+
+	for _, cookie := range fields.cookies {
+		name, _, _ := strings.Cut(cookie, "=")
+		cookieNames = append(cookieNames, name)
+	}
+
+	sort.Strings(cookieNames)
+
+	version := "11"
+	if http2 {
+		version = "20"
+	}
+
+	parts := []string{
+		fmt.Sprintf("%s%s%s%s%02d%s", method, version, cookie, referer, min99(len(fields.headers)), language),
+		strings.Join(fields.headers, ","),
+		strings.Join(cookieNames, ","),
+		strings.Join(cookies, ","),
+	}
+
+	if !raw {
+		for i := 1; i < len(parts); i++ {
+			parts[i] = ja4Hash(parts[i])
+		}
+	}
+
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,49 @@
+package tls_client
+
+import (
+	"github.com/bogdanfinn/fhttp/http2"
+	tls "github.com/bogdanfinn/utls"
+)
+
+// ProfileBuilder creates a client profile from another one, e.g. ProfileFrom(Chrome_107).WithConnectionFlow(12517377).Build().
+// Everything passed to it is copied, so neither the profiles nor the arguments can be changed through each other.
+type ProfileBuilder struct {
+	profile ClientProfile
+}
+
+// ProfileFrom starts a builder with the fields of the profile.
+func ProfileFrom(profile ClientProfile) *ProfileBuilder {
+	return &ProfileBuilder{profile: profile.copy()}
+}
+
+func (b *ProfileBuilder) WithClientHelloId(clientHelloId tls.ClientHelloID) *ProfileBuilder {
+	b.profile.clientHelloId = clientHelloId
+	return b
+}
+
+// WithSettings replaces the http2 settings and their order, nil settings send DefaultH2Settings.
+func (b *ProfileBuilder) WithSettings(settings map[http2.SettingID]uint32, settingsOrder []http2.SettingID) *ProfileBuilder {
+	b.profile.settings = copySettings(settings)
+	b.profile.settingsOrder = append([]http2.SettingID(nil), settingsOrder...)
+	return b
+}
+
+func (b *ProfileBuilder) WithPseudoHeaderOrder(pseudoHeaderOrder []string) *ProfileBuilder {
+	b.profile.pseudoHeaderOrder = append([]string(nil), pseudoHeaderOrder...)
+	return b
+}
+
+func (b *ProfileBuilder) WithConnectionFlow(connectionFlow uint32) *ProfileBuilder {
+	b.profile.connectionFlow = connectionFlow
+	return b
+}
+
+func (b *ProfileBuilder) WithPriorities(priorities []http2.Priority) *ProfileBuilder {
+	b.profile.priorities = append([]http2.Priority(nil), priorities...)
+	return b
+}
+
+// Build returns the profile, the builder can be changed and built again afterwards.
+func (b *ProfileBuilder) Build() ClientProfile {
+	return b.profile.copy()
+}
@@ -0,0 +1,803 @@
+package tls_client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/bogdanfinn/fhttp/http2"
+	tls "github.com/bogdanfinn/utls"
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileFormat is the encoding of a profile file.
+type ProfileFormat int
+
+const (
+	ProfileFormatJSON ProfileFormat = iota
+	ProfileFormatYAML
+)
+
+// profileFile is the declarative form of a ClientProfile, see the Readme for a description of the format.
+type profileFile struct {
+	ClientHello profileClientHello `json:"clientHello" yaml:"clientHello"`
+	Http2       profileHttp2       `json:"http2" yaml:"http2"`
+}
+
+type profileClientHello struct {
+	// Client and Version name the client hello, a name known to utls has to describe the spec of utls.
+	Client               string             `json:"client" yaml:"client"`
+	Version              string             `json:"version" yaml:"version"`
+	RandomExtensionOrder bool               `json:"randomExtensionOrder,omitempty" yaml:"randomExtensionOrder,omitempty"`
+	TLSVersionMin        profileValue       `json:"tlsVersionMin,omitempty" yaml:"tlsVersionMin,omitempty"`
+	TLSVersionMax        profileValue       `json:"tlsVersionMax,omitempty" yaml:"tlsVersionMax,omitempty"`
+	CipherSuites         []profileValue     `json:"cipherSuites" yaml:"cipherSuites"`
+	CompressionMethods   []profileValue     `json:"compressionMethods" yaml:"compressionMethods"`
+	Extensions           []profileExtension `json:"extensions" yaml:"extensions"`
+}
+
+// profileExtension is a tls extension, only the parameters of its name are used.
+type profileExtension struct {
+	Name string `json:"name" yaml:"name"`
+	// Id is the extension id of generic extensions.
+	Id profileValue `json:"id,omitempty" yaml:"id,omitempty"`
+	// Data is the hex encoded body of cookie and generic extensions.
+	Data                string            `json:"data,omitempty" yaml:"data,omitempty"`
+	ServerName          string            `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+	Protocols           []string          `json:"protocols,omitempty" yaml:"protocols,omitempty"`
+	Groups              []profileValue    `json:"groups,omitempty" yaml:"groups,omitempty"`
+	PointFormats        []profileValue    `json:"pointFormats,omitempty" yaml:"pointFormats,omitempty"`
+	SignatureAlgorithms []profileValue    `json:"signatureAlgorithms,omitempty" yaml:"signatureAlgorithms,omitempty"`
+	Renegotiation       string            `json:"renegotiation,omitempty" yaml:"renegotiation,omitempty"`
+	PaddingStyle        string            `json:"paddingStyle,omitempty" yaml:"paddingStyle,omitempty"`
+	PaddingLength       int               `json:"paddingLength,omitempty" yaml:"paddingLength,omitempty"`
+	WillPad             bool              `json:"willPad,omitempty" yaml:"willPad,omitempty"`
+	Algorithms          []profileValue    `json:"algorithms,omitempty" yaml:"algorithms,omitempty"`
+	KeyShares           []profileKeyShare `json:"keyShares,omitempty" yaml:"keyShares,omitempty"`
+	Modes               []profileValue    `json:"modes,omitempty" yaml:"modes,omitempty"`
+	Versions            []profileValue    `json:"versions,omitempty" yaml:"versions,omitempty"`
+	OldExtensionId      bool              `json:"oldExtensionId,omitempty" yaml:"oldExtensionId,omitempty"`
+	Limit               uint16            `json:"limit,omitempty" yaml:"limit,omitempty"`
+	MajorVersion        uint8             `json:"majorVersion,omitempty" yaml:"majorVersion,omitempty"`
+	MinorVersion        uint8             `json:"minorVersion,omitempty" yaml:"minorVersion,omitempty"`
+	KeyParameters       []profileValue    `json:"keyParameters,omitempty" yaml:"keyParameters,omitempty"`
+}
+
+type profileKeyShare struct {
+	Group profileValue `json:"group" yaml:"group"`
+	// Data is the hex encoded key, empty keys are generated per connection.
+	Data string `json:"data,omitempty" yaml:"data,omitempty"`
+}
+
+type profileHttp2 struct {
+	// Settings are sent in the order of the list, a profile without settings sends DefaultH2Settings.
+	Settings          []profileSetting  `json:"settings,omitempty" yaml:"settings,omitempty"`
+	ConnectionFlow    uint32            `json:"connectionFlow,omitempty" yaml:"connectionFlow,omitempty"`
+	Priorities        []profilePriority `json:"priorities,omitempty" yaml:"priorities,omitempty"`
+	PseudoHeaderOrder []string          `json:"pseudoHeaderOrder,omitempty" yaml:"pseudoHeaderOrder,omitempty"`
+}
+
+type profileSetting struct {
+	Id    profileValue `json:"id" yaml:"id"`
+	Value uint32       `json:"value" yaml:"value"`
+}
+
+type profilePriority struct {
+	StreamId  uint32 `json:"streamId" yaml:"streamId"`
+	StreamDep uint32 `json:"streamDep" yaml:"streamDep"`
+	Exclusive bool   `json:"exclusive" yaml:"exclusive"`
+	Weight    uint8  `json:"weight" yaml:"weight"`
+}
+
+// profileValue is a number which is written by its name if it has one, e.g. "GREASE", "X25519" or "1.3".
+type profileValue string
+
+func (v profileValue) isNumber() bool {
+	_, err := strconv.ParseUint(string(v), 10, 64)
+
+	return err == nil
+}
+
+func (v profileValue) MarshalJSON() ([]byte, error) {
+	if v.isNumber() {
+		return []byte(v), nil
+	}
+
+	return json.Marshal(string(v))
+}
+
+func (v *profileValue) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return err
+		}
+
+		*v = profileValue(name)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+
+	*v = profileValue(number)
+
+	return nil
+}
+
+func (v profileValue) MarshalYAML() (interface{}, error) {
+	if v.isNumber() {
+		number, _ := strconv.ParseUint(string(v), 10, 64)
+		return number, nil
+	}
+
+	return string(v), nil
+}
+
+func (v *profileValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected a number or a name", node.Line)
+	}
+
+	*v = profileValue(node.Value)
+
+	return nil
+}
+
+// valueNames maps the names used in profile files to their values.
+type valueNames map[string]uint16
+
+func (n valueNames) encode(value uint16) profileValue {
+	for name, named := range n {
+		if named == value {
+			return profileValue(name)
+		}
+	}
+
+	return profileValue(strconv.FormatUint(uint64(value), 10))
+}
+
+func (n valueNames) encodeAll(values []uint16) []profileValue {
+	encoded := make([]profileValue, 0, len(values))
+	for _, value := range values {
+		encoded = append(encoded, n.encode(value))
+	}
+
+	return encoded
+}
+
+func (n valueNames) decode(v profileValue, bitSize int) (uint16, error) {
+	if value, ok := n[string(v)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.ParseUint(string(v), 0, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", string(v))
+	}
+
+	return uint16(value), nil
+}
+
+func (n valueNames) decodeAll(values []profileValue, bitSize int) ([]uint16, error) {
+	var decoded []uint16
+	for _, v := range values {
+		value, err := n.decode(v, bitSize)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded = append(decoded, value)
+	}
+
+	return decoded, nil
+}
+
+var (
+	cipherSuiteNames      = newCipherSuiteNames()
+	tlsVersionNames       = valueNames(tlsVersions)
+	curveNames            = newValueNames(curves)
+	signatureNames        = newValueNames(signatureAlgorithms)
+	certCompressionNames  = newValueNames(certCompression)
+	h2SettingNames        = newValueNames(H2SettingsMap)
+	renegotiationNames    = map[tls.RenegotiationSupport]string{tls.RenegotiateNever: "never", tls.RenegotiateOnceAsClient: "once", tls.RenegotiateFreelyAsClient: "freely"}
+	boringPaddingStyle    = "boringssl"
+	profileFileExtensions = []string{
+		"grease", "server_name", "status_request", "status_request_v2", "supported_groups", "ec_point_formats",
+		"signature_algorithms", "signature_algorithms_cert", "renegotiation_info", "application_layer_protocol_negotiation",
+		"application_settings", "alps", "signed_certificate_timestamp", "session_ticket", "extended_master_secret",
+		"padding", "compress_certificate", "key_share", "pre_shared_key", "psk_key_exchange_modes", "supported_versions",
+		"cookie", "channel_id", "record_size_limit", "delegated_credentials", "token_binding", "next_protocol_negotiation", "generic",
+	}
+)
+
+func newValueNames[T ~uint16](values map[string]T) valueNames {
+	names := make(valueNames, len(values))
+	for name, value := range values {
+		names[name] = uint16(value)
+	}
+
+	return names
+}
+
+func newCipherSuiteNames() valueNames {
+	names := valueNames{"GREASE": tls.GREASE_PLACEHOLDER}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		names[suite.Name] = suite.ID
+	}
+
+	return names
+}
+
+// MarshalProfile encodes the profile in the declarative profile format.
+func MarshalProfile(profile ClientProfile, format ProfileFormat) ([]byte, error) {
+	file, err := encodeProfile(profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal client profile: %w", err)
+	}
+
+	switch format {
+	case ProfileFormatJSON:
+		return json.MarshalIndent(file, "", "  ")
+	case ProfileFormatYAML:
+		var buf bytes.Buffer
+
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(file); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), encoder.Close()
+	default:
+		return nil, fmt.Errorf("failed to marshal client profile: unknown format %d", format)
+	}
+}
+
+// LoadProfile decodes a profile written in the declarative profile format, as JSON or as YAML.
+func LoadProfile(data []byte) (ClientProfile, error) {
+	var file profileFile
+
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&file); err != nil {
+			return ClientProfile{}, fmt.Errorf("failed to load client profile: %w", err)
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(trimmed))
+		decoder.KnownFields(true)
+
+		if err := decoder.Decode(&file); err != nil {
+			return ClientProfile{}, fmt.Errorf("failed to load client profile: %w", err)
+		}
+	}
+
+	profile, err := decodeProfile(file)
+	if err != nil {
+		return ClientProfile{}, fmt.Errorf("failed to load client profile: %w", err)
+	}
+
+	return profile, nil
+}
+
+func encodeProfile(profile ClientProfile) (profileFile, error) {
+	id := profile.clientHelloId
+
+	spec, err := clientHelloSpec(id)
+	if err != nil {
+		return profileFile{}, err
+	}
+
+	clientHello, err := encodeClientHello(spec)
+	if err != nil {
+		return profileFile{}, fmt.Errorf("client hello %s: %w", id.Str(), err)
+	}
+
+	clientHello.Client = id.Client
+	clientHello.Version = id.Version
+	clientHello.RandomExtensionOrder = id.RandomExtensionOrder
+
+	file := profileFile{
+		ClientHello: clientHello,
+		Http2: profileHttp2{
+			ConnectionFlow:    profile.connectionFlow,
+			PseudoHeaderOrder: profile.pseudoHeaderOrder,
+		},
+	}
+
+	// the transport sends the settings of the order only, the file lists the sent ones
+	written := make(map[http2.SettingID]bool, len(profile.settingsOrder))
+	for _, id := range profile.settingsOrder {
+		value, ok := profile.settings[id]
+		if !ok || written[id] {
+			continue
+		}
+
+		file.Http2.Settings = append(file.Http2.Settings, profileSetting{Id: h2SettingNames.encode(uint16(id)), Value: value})
+		written[id] = true
+	}
+
+	for _, priority := range profile.priorities {
+		file.Http2.Priorities = append(file.Http2.Priorities, profilePriority{
+			StreamId:  priority.StreamID,
+			StreamDep: priority.PriorityParam.StreamDep,
+			Exclusive: priority.PriorityParam.Exclusive,
+			Weight:    priority.PriorityParam.Weight,
+		})
+	}
+
+	return file, nil
+}
+
+func decodeProfile(file profileFile) (ClientProfile, error) {
+	clientHelloId, err := decodeClientHelloId(file.ClientHello)
+	if err != nil {
+		return ClientProfile{}, err
+	}
+
+	profile := ClientProfile{
+		clientHelloId:     clientHelloId,
+		pseudoHeaderOrder: file.Http2.PseudoHeaderOrder,
+		connectionFlow:    file.Http2.ConnectionFlow,
+	}
+
+	if len(file.Http2.Settings) > 0 {
+		profile.settings = make(map[http2.SettingID]uint32, len(file.Http2.Settings))
+	}
+
+	for _, setting := range file.Http2.Settings {
+		id, err := h2SettingNames.decode(setting.Id, 16)
+		if err != nil {
+			return ClientProfile{}, fmt.Errorf("http2 setting: %w", err)
+		}
+
+		if _, ok := profile.settings[http2.SettingID(id)]; ok {
+			return ClientProfile{}, fmt.Errorf("http2 setting %s is listed twice", setting.Id)
+		}
+
+		profile.settings[http2.SettingID(id)] = setting.Value
+		profile.settingsOrder = append(profile.settingsOrder, http2.SettingID(id))
+	}
+
+	for _, priority := range file.Http2.Priorities {
+		profile.priorities = append(profile.priorities, http2.Priority{
+			StreamID: priority.StreamId,
+			PriorityParam: http2.PriorityParam{
+				StreamDep: priority.StreamDep,
+				Exclusive: priority.Exclusive,
+				Weight:    priority.Weight,
+			},
+		})
+	}
+
+	return profile, nil
+}
+
+// decodeClientHelloId returns the utls id for client hellos which name and describe a spec of utls and a custom id for all others.
+func decodeClientHelloId(clientHello profileClientHello) (tls.ClientHelloID, error) {
+	if clientHello.Client == "" {
+		return tls.ClientHelloID{}, fmt.Errorf("client hello: the client name is empty")
+	}
+
+	// the spec is decoded once to fail early, the factory creates fresh extensions for every handshake
+	if _, err := decodeClientHello(clientHello); err != nil {
+		return tls.ClientHelloID{}, fmt.Errorf("client hello %s-%s: %w", clientHello.Client, clientHello.Version, err)
+	}
+
+	clientHelloId := tls.ClientHelloID{
+		Client:               clientHello.Client,
+		RandomExtensionOrder: clientHello.RandomExtensionOrder,
+		Version:              clientHello.Version,
+	}
+
+	if builtIn, err := tls.UTLSIdToSpec(clientHelloId); err == nil {
+		// utls always sends its own spec for the names it knows
+		encoded, err := encodeClientHello(builtIn)
+		if err != nil {
+			return tls.ClientHelloID{}, fmt.Errorf("client hello %s: %w", clientHelloId.Str(), err)
+		}
+
+		encoded.Client, encoded.Version, encoded.RandomExtensionOrder = clientHello.Client, clientHello.Version, clientHello.RandomExtensionOrder
+		if !sameClientHello(encoded, clientHello) {
+			return tls.ClientHelloID{}, fmt.Errorf("client hello %s differs from the one of utls with that name, use another client name", clientHelloId.Str())
+		}
+
+		// like the ids declared by utls
+		clientHelloId.SpecFactory = tls.EmptyClientHelloSpecFactory
+
+		return clientHelloId, nil
+	}
+
+	clientHelloId.SpecFactory = func() (tls.ClientHelloSpec, error) {
+		return decodeClientHello(clientHello)
+	}
+
+	return clientHelloId, nil
+}
+
+// sameClientHello compares the encoded client hellos, which does not tell empty lists from missing ones.
+func sameClientHello(a, b profileClientHello) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+func encodeClientHello(spec tls.ClientHelloSpec) (profileClientHello, error) {
+	clientHello := profileClientHello{
+		CipherSuites:       cipherSuiteNames.encodeAll(spec.CipherSuites),
+		CompressionMethods: encodeUint8s(spec.CompressionMethods),
+	}
+
+	if spec.TLSVersMin != 0 {
+		clientHello.TLSVersionMin = tlsVersionNames.encode(spec.TLSVersMin)
+	}
+
+	if spec.TLSVersMax != 0 {
+		clientHello.TLSVersionMax = tlsVersionNames.encode(spec.TLSVersMax)
+	}
+
+	for _, extension := range spec.Extensions {
+		encoded, err := encodeExtension(extension)
+		if err != nil {
+			return profileClientHello{}, err
+		}
+
+		clientHello.Extensions = append(clientHello.Extensions, encoded)
+	}
+
+	return clientHello, nil
+}
+
+func decodeClientHello(clientHello profileClientHello) (tls.ClientHelloSpec, error) {
+	var spec tls.ClientHelloSpec
+	var err error
+
+	if clientHello.TLSVersionMin != "" {
+		if spec.TLSVersMin, err = tlsVersionNames.decode(clientHello.TLSVersionMin, 16); err != nil {
+			return tls.ClientHelloSpec{}, fmt.Errorf("tls version: %w", err)
+		}
+	}
+
+	if clientHello.TLSVersionMax != "" {
+		if spec.TLSVersMax, err = tlsVersionNames.decode(clientHello.TLSVersionMax, 16); err != nil {
+			return tls.ClientHelloSpec{}, fmt.Errorf("tls version: %w", err)
+		}
+	}
+
+	if spec.CipherSuites, err = cipherSuiteNames.decodeAll(clientHello.CipherSuites, 16); err != nil {
+		return tls.ClientHelloSpec{}, fmt.Errorf("cipher suite: %w", err)
+	}
+
+	if spec.CompressionMethods, err = decodeUint8s(clientHello.CompressionMethods); err != nil {
+		return tls.ClientHelloSpec{}, fmt.Errorf("compression method: %w", err)
+	}
+
+	for _, extension := range clientHello.Extensions {
+		decoded, err := decodeExtension(extension)
+		if err != nil {
+			return tls.ClientHelloSpec{}, fmt.Errorf("extension %s: %w", extension.Name, err)
+		}
+
+		spec.Extensions = append(spec.Extensions, decoded)
+	}
+
+	return spec, nil
+}
+
+func encodeExtension(extension tls.TLSExtension) (profileExtension, error) {
+	switch ext := extension.(type) {
+	case *tls.UtlsGREASEExtension:
+		// utls chooses the value and body of GREASE extensions per connection
+		return profileExtension{Name: "grease"}, nil
+	case *tls.SNIExtension:
+		return profileExtension{Name: "server_name", ServerName: ext.ServerName}, nil
+	case *tls.StatusRequestExtension:
+		return profileExtension{Name: "status_request"}, nil
+	case *tls.StatusRequestV2Extension:
+		return profileExtension{Name: "status_request_v2"}, nil
+	case *tls.SupportedCurvesExtension:
+		groups := make([]uint16, 0, len(ext.Curves))
+		for _, curve := range ext.Curves {
+			groups = append(groups, uint16(curve))
+		}
+
+		return profileExtension{Name: "supported_groups", Groups: curveNames.encodeAll(groups)}, nil
+	case *tls.SupportedPointsExtension:
+		return profileExtension{Name: "ec_point_formats", PointFormats: encodeUint8s(ext.SupportedPoints)}, nil
+	case *tls.SignatureAlgorithmsExtension:
+		return profileExtension{Name: "signature_algorithms", SignatureAlgorithms: encodeSignatureSchemes(ext.SupportedSignatureAlgorithms)}, nil
+	case *tls.SignatureAlgorithmsCertExtension:
+		return profileExtension{Name: "signature_algorithms_cert", SignatureAlgorithms: encodeSignatureSchemes(ext.SupportedSignatureAlgorithms)}, nil
+	case *tls.RenegotiationInfoExtension:
+		name, ok := renegotiationNames[ext.Renegotiation]
+		if !ok {
+			return profileExtension{}, fmt.Errorf("unsupported renegotiation %d", ext.Renegotiation)
+		}
+
+		return profileExtension{Name: "renegotiation_info", Renegotiation: name}, nil
+	case *tls.ALPNExtension:
+		return profileExtension{Name: "application_layer_protocol_negotiation", Protocols: ext.AlpnProtocols}, nil
+	case *tls.ApplicationSettingsExtension:
+		return profileExtension{Name: "application_settings", Protocols: ext.SupportedProtocols}, nil
+	case *tls.ALPSExtension:
+		return profileExtension{Name: "alps", Protocols: ext.SupportedProtocols}, nil
+	case *tls.SCTExtension:
+		return profileExtension{Name: "signed_certificate_timestamp"}, nil
+	case *tls.SessionTicketExtension:
+		// sessions belong to a connection, not to a profile
+		return profileExtension{Name: "session_ticket"}, nil
+	case *tls.UtlsExtendedMasterSecretExtension:
+		return profileExtension{Name: "extended_master_secret"}, nil
+	case *tls.UtlsPaddingExtension:
+		if ext.GetPaddingLen == nil {
+			return profileExtension{Name: "padding", PaddingLength: ext.PaddingLen, WillPad: ext.WillPad}, nil
+		}
+
+		if reflect.ValueOf(ext.GetPaddingLen).Pointer() != reflect.ValueOf(tls.BoringPaddingStyle).Pointer() {
+			return profileExtension{}, fmt.Errorf("padding extension with a custom padding func is not supported")
+		}
+
+		return profileExtension{Name: "padding", PaddingStyle: boringPaddingStyle}, nil
+	case *tls.UtlsCompressCertExtension:
+		algorithms := make([]uint16, 0, len(ext.Algorithms))
+		for _, algorithm := range ext.Algorithms {
+			algorithms = append(algorithms, uint16(algorithm))
+		}
+
+		return profileExtension{Name: "compress_certificate", Algorithms: certCompressionNames.encodeAll(algorithms)}, nil
+	case *tls.KeyShareExtension:
+		encoded := profileExtension{Name: "key_share"}
+		for _, keyShare := range ext.KeyShares {
+			encoded.KeyShares = append(encoded.KeyShares, profileKeyShare{Group: curveNames.encode(uint16(keyShare.Group)), Data: hex.EncodeToString(keyShare.Data)})
+		}
+
+		return encoded, nil
+	case *tls.PreSharedKeyExtension:
+		return profileExtension{Name: "pre_shared_key"}, nil
+	case *tls.PSKKeyExchangeModesExtension:
+		return profileExtension{Name: "psk_key_exchange_modes", Modes: encodeUint8s(ext.Modes)}, nil
+	case *tls.SupportedVersionsExtension:
+		return profileExtension{Name: "supported_versions", Versions: tlsVersionNames.encodeAll(ext.Versions)}, nil
+	case *tls.CookieExtension:
+		return profileExtension{Name: "cookie", Data: hex.EncodeToString(ext.Cookie)}, nil
+	case *tls.FakeChannelIDExtension:
+		return profileExtension{Name: "channel_id", OldExtensionId: ext.OldExtensionID}, nil
+	case *tls.FakeRecordSizeLimitExtension:
+		return profileExtension{Name: "record_size_limit", Limit: ext.Limit}, nil
+	case *tls.DelegatedCredentialsExtension:
+		return profileExtension{Name: "delegated_credentials", SignatureAlgorithms: encodeSignatureSchemes(ext.AlgorithmsSignature)}, nil
+	case *tls.FakeTokenBindingExtension:
+		return profileExtension{Name: "token_binding", MajorVersion: ext.MajorVersion, MinorVersion: ext.MinorVersion, KeyParameters: encodeUint8s(ext.KeyParameters)}, nil
+	case *tls.NPNExtension:
+		return profileExtension{Name: "next_protocol_negotiation", Protocols: ext.NextProtos}, nil
+	case *tls.GenericExtension:
+		return profileExtension{Name: "generic", Id: profileValue(strconv.FormatUint(uint64(ext.Id), 10)), Data: hex.EncodeToString(ext.Data)}, nil
+	default:
+		return profileExtension{}, fmt.Errorf("unsupported extension %T", extension)
+	}
+}
+
+func decodeExtension(extension profileExtension) (tls.TLSExtension, error) {
+	switch extension.Name {
+	case "grease":
+		return &tls.UtlsGREASEExtension{}, nil
+	case "server_name":
+		return &tls.SNIExtension{ServerName: extension.ServerName}, nil
+	case "status_request":
+		return &tls.StatusRequestExtension{}, nil
+	case "status_request_v2":
+		return &tls.StatusRequestV2Extension{}, nil
+	case "supported_groups":
+		groups, err := curveNames.decodeAll(extension.Groups, 16)
+		if err != nil {
+			return nil, err
+		}
+
+		curves := make([]tls.CurveID, 0, len(groups))
+		for _, group := range groups {
+			curves = append(curves, tls.CurveID(group))
+		}
+
+		return &tls.SupportedCurvesExtension{Curves: curves}, nil
+	case "ec_point_formats":
+		points, err := decodeUint8s(extension.PointFormats)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tls.SupportedPointsExtension{SupportedPoints: points}, nil
+	case "signature_algorithms":
+		schemes, err := decodeSignatureSchemes(extension.SignatureAlgorithms)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tls.SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: schemes}, nil
+	case "signature_algorithms_cert":
+		schemes, err := decodeSignatureSchemes(extension.SignatureAlgorithms)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tls.SignatureAlgorithmsCertExtension{SupportedSignatureAlgorithms: schemes}, nil
+	case "renegotiation_info":
+		for renegotiation, name := range renegotiationNames {
+			if name == extension.Renegotiation {
+				return &tls.RenegotiationInfoExtension{Renegotiation: renegotiation}, nil
+			}
+		}
+
+		return nil, fmt.Errorf("invalid renegotiation %q", extension.Renegotiation)
+	case "application_layer_protocol_negotiation":
+		return &tls.ALPNExtension{AlpnProtocols: extension.Protocols}, nil
+	case "application_settings":
+		return &tls.ApplicationSettingsExtension{SupportedProtocols: extension.Protocols}, nil
+	case "alps":
+		return &tls.ALPSExtension{SupportedProtocols: extension.Protocols}, nil
+	case "signed_certificate_timestamp":
+		return &tls.SCTExtension{}, nil
+	case "session_ticket":
+		return &tls.SessionTicketExtension{}, nil
+	case "extended_master_secret":
+		return &tls.UtlsExtendedMasterSecretExtension{}, nil
+	case "padding":
+		switch extension.PaddingStyle {
+		case "":
+			return &tls.UtlsPaddingExtension{PaddingLen: extension.PaddingLength, WillPad: extension.WillPad}, nil
+		case boringPaddingStyle:
+			return &tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle}, nil
+		default:
+			return nil, fmt.Errorf("invalid padding style %q", extension.PaddingStyle)
+		}
+	case "compress_certificate":
+		algorithms, err := certCompressionNames.decodeAll(extension.Algorithms, 16)
+		if err != nil {
+			return nil, err
+		}
+
+		compressions := make([]tls.CertCompressionAlgo, 0, len(algorithms))
+		for _, algorithm := range algorithms {
+			compressions = append(compressions, tls.CertCompressionAlgo(algorithm))
+		}
+
+		return &tls.UtlsCompressCertExtension{Algorithms: compressions}, nil
+	case "key_share":
+		var keyShares []tls.KeyShare
+		for _, keyShare := range extension.KeyShares {
+			group, err := curveNames.decode(keyShare.Group, 16)
+			if err != nil {
+				return nil, err
+			}
+
+			data, err := decodeHex(keyShare.Data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key share data: %w", err)
+			}
+
+			keyShares = append(keyShares, tls.KeyShare{Group: tls.CurveID(group), Data: data})
+		}
+
+		return &tls.KeyShareExtension{KeyShares: keyShares}, nil
+	case "pre_shared_key":
+		return &tls.PreSharedKeyExtension{}, nil
+	case "psk_key_exchange_modes":
+		modes, err := decodeUint8s(extension.Modes)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tls.PSKKeyExchangeModesExtension{Modes: modes}, nil
+	case "supported_versions":
+		versions, err := tlsVersionNames.decodeAll(extension.Versions, 16)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tls.SupportedVersionsExtension{Versions: versions}, nil
+	case "cookie":
+		cookie, err := decodeHex(extension.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data: %w", err)
+		}
+
+		return &tls.CookieExtension{Cookie: cookie}, nil
+	case "channel_id":
+		return &tls.FakeChannelIDExtension{OldExtensionID: extension.OldExtensionId}, nil
+	case "record_size_limit":
+		return &tls.FakeRecordSizeLimitExtension{Limit: extension.Limit}, nil
+	case "delegated_credentials":
+		schemes, err := decodeSignatureSchemes(extension.SignatureAlgorithms)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tls.DelegatedCredentialsExtension{AlgorithmsSignature: schemes}, nil
+	case "token_binding":
+		parameters, err := decodeUint8s(extension.KeyParameters)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tls.FakeTokenBindingExtension{MajorVersion: extension.MajorVersion, MinorVersion: extension.MinorVersion, KeyParameters: parameters}, nil
+	case "next_protocol_negotiation":
+		return &tls.NPNExtension{NextProtos: extension.Protocols}, nil
+	case "generic":
+		id, err := valueNames(nil).decode(extension.Id, 16)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := decodeHex(extension.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data: %w", err)
+		}
+
+		return &tls.GenericExtension{Id: id, Data: data}, nil
+	default:
+		return nil, fmt.Errorf("unknown extension, the known ones are %v", profileFileExtensions)
+	}
+}
+
+// decodeHex returns nil for empty data like the extensions of utls have it.
+func decodeHex(data string) ([]byte, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	return hex.DecodeString(data)
+}
+
+func encodeUint8s(values []uint8) []profileValue {
+	widened := make([]uint16, 0, len(values))
+	for _, value := range values {
+		widened = append(widened, uint16(value))
+	}
+
+	return valueNames(nil).encodeAll(widened)
+}
+
+func decodeUint8s(values []profileValue) ([]uint8, error) {
+	decoded, err := valueNames(nil).decodeAll(values, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	var narrowed []uint8
+	for _, value := range decoded {
+		narrowed = append(narrowed, uint8(value))
+	}
+
+	return narrowed, nil
+}
+
+func encodeSignatureSchemes(schemes []tls.SignatureScheme) []profileValue {
+	values := make([]uint16, 0, len(schemes))
+	for _, scheme := range schemes {
+		values = append(values, uint16(scheme))
+	}
+
+	return signatureNames.encodeAll(values)
+}
+
+func decodeSignatureSchemes(values []profileValue) ([]tls.SignatureScheme, error) {
+	decoded, err := signatureNames.decodeAll(values, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemes []tls.SignatureScheme
+	for _, value := range decoded {
+		schemes = append(schemes, tls.SignatureScheme(value))
+	}
+
+	return schemes, nil
+}
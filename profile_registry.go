@@ -0,0 +1,225 @@
+package tls_client
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownProfile is returned when a profile name is neither registered nor an alias.
+var ErrUnknownProfile = errors.New("unknown client profile")
+
+// ProfileInfo describes a registered client profile.
+type ProfileInfo struct {
+	Name     string
+	Browser  string
+	Version  string
+	Platform string
+	// ReleaseDate is the release date of the browser version, zero if unknown.
+	ReleaseDate time.Time
+	Http2       bool
+	// Aliases are the alternative names which currently point to the profile, e.g. chrome_latest.
+	Aliases []string
+}
+
+type registeredProfile struct {
+	profile ClientProfile
+	info    ProfileInfo
+}
+
+// profileRegistry holds the profiles which can be looked up by name, e.g. by the shared library.
+type profileRegistry struct {
+	mu       sync.RWMutex
+	profiles map[string]registeredProfile
+	aliases  map[string]string
+}
+
+var defaultProfileRegistry = newDefaultProfileRegistry()
+
+// RegisterProfile adds a profile under the name of its info. Names and aliases have to be unique.
+func RegisterProfile(profile ClientProfile, info ProfileInfo) error {
+	return defaultProfileRegistry.register(profile, info)
+}
+
+// RegisterProfileAlias points the alias to the registered profile, replacing the previous target of the alias.
+func RegisterProfileAlias(alias string, name string) error {
+	return defaultProfileRegistry.registerAlias(alias, name)
+}
+
+// LookupProfile returns the profile registered under the name or alias.
+func LookupProfile(name string) (ClientProfile, error) {
+	registered, err := defaultProfileRegistry.lookup(name)
+
+	return registered.profile, err
+}
+
+// LookupProfileInfo returns the info of the profile registered under the name or alias.
+func LookupProfileInfo(name string) (ProfileInfo, error) {
+	registered, err := defaultProfileRegistry.lookup(name)
+
+	return registered.info, err
+}
+
+// ListProfiles returns the infos of all registered profiles sorted by name.
+func ListProfiles() []ProfileInfo {
+	return defaultProfileRegistry.list()
+}
+
+func newProfileRegistry() *profileRegistry {
+	return &profileRegistry{
+		profiles: make(map[string]registeredProfile),
+		aliases:  make(map[string]string),
+	}
+}
+
+func (r *profileRegistry) register(profile ClientProfile, info ProfileInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info.Name == "" {
+		return errors.New("failed to register client profile: the name is empty")
+	}
+
+	if r.taken(info.Name) {
+		return fmt.Errorf("failed to register client profile: %s is already registered", info.Name)
+	}
+
+	for _, alias := range info.Aliases {
+		if alias == info.Name || r.taken(alias) {
+			return fmt.Errorf("failed to register client profile %s: alias %s is already registered", info.Name, alias)
+		}
+	}
+
+	aliases := info.Aliases
+	info.Aliases = nil
+	r.profiles[info.Name] = registeredProfile{profile: profile, info: info}
+
+	for _, alias := range aliases {
+		r.aliases[alias] = info.Name
+	}
+
+	return nil
+}
+
+func (r *profileRegistry) registerAlias(alias string, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.profiles[alias]; ok {
+		return fmt.Errorf("failed to register alias: %s is the name of a client profile", alias)
+	}
+
+	if _, ok := r.profiles[name]; !ok {
+		return fmt.Errorf("failed to register alias %s: %w: %s", alias, ErrUnknownProfile, name)
+	}
+
+	r.aliases[alias] = name
+
+	return nil
+}
+
+func (r *profileRegistry) lookup(name string) (registeredProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if target, ok := r.aliases[name]; ok {
+		name = target
+	}
+
+	registered, ok := r.profiles[name]
+	if !ok {
+		return registeredProfile{}, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+
+	registered.info.Aliases = r.aliasesOf(name)
+
+	return registered, nil
+}
+
+func (r *profileRegistry) list() []ProfileInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]ProfileInfo, 0, len(r.profiles))
+	for name, registered := range r.profiles {
+		info := registered.info
+		info.Aliases = r.aliasesOf(name)
+
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos
+}
+
+func (r *profileRegistry) taken(name string) bool {
+	_, isProfile := r.profiles[name]
+	_, isAlias := r.aliases[name]
+
+	return isProfile || isAlias
+}
+
+func (r *profileRegistry) aliasesOf(name string) []string {
+	var aliases []string
+	for alias, target := range r.aliases {
+		if target == name {
+			aliases = append(aliases, alias)
+		}
+	}
+
+	sort.Strings(aliases)
+
+	return aliases
+}
+
+func newDefaultProfileRegistry() *profileRegistry {
+	r := newProfileRegistry()
+
+	date := func(value string) time.Time {
+		t, _ := time.Parse("2006-01-02", value)
+		return t
+	}
+
+	builtIns := []struct {
+		profile ClientProfile
+		info    ProfileInfo
+	}{
+		{Chrome_103, ProfileInfo{Name: "chrome_103", Browser: "chrome", Version: "103", Platform: "windows", ReleaseDate: date("2022-06-21"), Http2: true}},
+		{Chrome_104, ProfileInfo{Name: "chrome_104", Browser: "chrome", Version: "104", Platform: "windows", ReleaseDate: date("2022-08-02"), Http2: true}},
+		{Chrome_105, ProfileInfo{Name: "chrome_105", Browser: "chrome", Version: "105", Platform: "windows", ReleaseDate: date("2022-08-30"), Http2: true}},
+		{Chrome_106, ProfileInfo{Name: "chrome_106", Browser: "chrome", Version: "106", Platform: "windows", ReleaseDate: date("2022-09-27"), Http2: true}},
+		{Chrome_107, ProfileInfo{Name: "chrome_107", Browser: "chrome", Version: "107", Platform: "windows", ReleaseDate: date("2022-10-25"), Http2: true, Aliases: []string{"chrome_latest"}}},
+		{Safari_15_6_1, ProfileInfo{Name: "safari_15_6_1", Browser: "safari", Version: "15.6.1", Platform: "macos", ReleaseDate: date("2022-08-17"), Http2: true}},
+		{Safari_16_0, ProfileInfo{Name: "safari_16_0", Browser: "safari", Version: "16.0", Platform: "macos", ReleaseDate: date("2022-09-12"), Http2: true, Aliases: []string{"safari_latest"}}},
+		{Safari_Ipad_15_6, ProfileInfo{Name: "safari_ipad_15_6", Browser: "safari", Version: "15.6", Platform: "ipados", ReleaseDate: date("2022-07-20"), Http2: true}},
+		{Safari_IOS_15_5, ProfileInfo{Name: "safari_ios_15_5", Browser: "safari", Version: "15.5", Platform: "ios", ReleaseDate: date("2022-05-16"), Http2: true}},
+		{Safari_IOS_15_6, ProfileInfo{Name: "safari_ios_15_6", Browser: "safari", Version: "15.6", Platform: "ios", ReleaseDate: date("2022-07-20"), Http2: true}},
+		{Safari_IOS_16_0, ProfileInfo{Name: "safari_ios_16_0", Browser: "safari", Version: "16.0", Platform: "ios", ReleaseDate: date("2022-09-12"), Http2: true, Aliases: []string{"safari_ios_latest"}}},
+		{Firefox_102, ProfileInfo{Name: "firefox_102", Browser: "firefox", Version: "102", Platform: "windows", ReleaseDate: date("2022-06-28"), Http2: true}},
+		{Firefox_104, ProfileInfo{Name: "firefox_104", Browser: "firefox", Version: "104", Platform: "windows", ReleaseDate: date("2022-08-23"), Http2: true}},
+		{Firefox_105, ProfileInfo{Name: "firefox_105", Browser: "firefox", Version: "105", Platform: "windows", ReleaseDate: date("2022-09-20"), Http2: true}},
+		{Firefox_106, ProfileInfo{Name: "firefox_106", Browser: "firefox", Version: "106", Platform: "windows", ReleaseDate: date("2022-10-18"), Http2: true, Aliases: []string{"firefox_latest"}}},
+		{Opera_89, ProfileInfo{Name: "opera_89", Browser: "opera", Version: "89", Platform: "windows", ReleaseDate: date("2022-07-07"), Http2: true}},
+		{Opera_90, ProfileInfo{Name: "opera_90", Browser: "opera", Version: "90", Platform: "windows", ReleaseDate: date("2022-08-18"), Http2: true}},
+		{Opera_91, ProfileInfo{Name: "opera_91", Browser: "opera", Version: "91", Platform: "windows", ReleaseDate: date("2022-09-14"), Http2: true, Aliases: []string{"opera_latest"}}},
+		{ZalandoAndroidMobile, ProfileInfo{Name: "zalando_android_mobile", Browser: "zalando", Platform: "android", Http2: true}},
+		{ZalandoIosMobile, ProfileInfo{Name: "zalando_ios_mobile", Browser: "zalando", Platform: "ios", Http2: true}},
+		{NikeIosMobile, ProfileInfo{Name: "nike_ios_mobile", Browser: "nike", Platform: "ios", Http2: true}},
+		{NikeAndroidMobile, ProfileInfo{Name: "nike_android_mobile", Browser: "nike", Platform: "android", Http2: true}},
+		{CloudflareCustom, ProfileInfo{Name: "cloudflare_custom", Browser: "cloudflare", Http2: false}},
+	}
+
+	for _, builtIn := range builtIns {
+		if err := r.register(builtIn.profile, builtIn.info); err != nil {
+			// Should never happen, the names of the built-in profiles are unique.
+			panic(err)
+		}
+	}
+
+	return r
+}
@@ -7,6 +7,7 @@ import (
 
 var DefaultClientProfile = Chrome_107
 
+// Deprecated: MappedTLSClients is not updated by RegisterProfile, use LookupProfile and ListProfiles instead.
 var MappedTLSClients = map[string]ClientProfile{
 	"chrome_103":             Chrome_103,
 	"chrome_104":             Chrome_104,
@@ -57,6 +58,56 @@ func (c ClientProfile) GetClientHelloSpec() (tls.ClientHelloSpec, error) {
 	return c.clientHelloId.ToSpec()
 }
 
+func (c ClientProfile) GetClientHelloId() tls.ClientHelloID {
+	return c.clientHelloId
+}
+
+// GetSettings returns a copy of the http2 settings, nil if the profile sends DefaultH2Settings.
+func (c ClientProfile) GetSettings() map[http2.SettingID]uint32 {
+	return copySettings(c.settings)
+}
+
+func (c ClientProfile) GetSettingsOrder() []http2.SettingID {
+	return append([]http2.SettingID(nil), c.settingsOrder...)
+}
+
+func (c ClientProfile) GetPseudoHeaderOrder() []string {
+	return append([]string(nil), c.pseudoHeaderOrder...)
+}
+
+func (c ClientProfile) GetConnectionFlow() uint32 {
+	return c.connectionFlow
+}
+
+func (c ClientProfile) GetPriorities() []http2.Priority {
+	return append([]http2.Priority(nil), c.priorities...)
+}
+
+// copy returns a profile which shares no maps or slices with c.
+func (c ClientProfile) copy() ClientProfile {
+	return ClientProfile{
+		clientHelloId:     c.clientHelloId,
+		settings:          c.GetSettings(),
+		settingsOrder:     c.GetSettingsOrder(),
+		pseudoHeaderOrder: c.GetPseudoHeaderOrder(),
+		connectionFlow:    c.connectionFlow,
+		priorities:        c.GetPriorities(),
+	}
+}
+
+func copySettings(settings map[http2.SettingID]uint32) map[http2.SettingID]uint32 {
+	if settings == nil {
+		return nil
+	}
+
+	copied := make(map[http2.SettingID]uint32, len(settings))
+	for id, value := range settings {
+		copied[id] = value
+	}
+
+	return copied
+}
+
 var Chrome_107 = ClientProfile{
 	clientHelloId: tls.HelloChrome_107,
 	settings: map[http2.SettingID]uint32{
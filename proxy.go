@@ -0,0 +1,210 @@
+package tls_client
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	http "github.com/bogdanfinn/fhttp"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// ProxyFunc returns the proxy which should be used for the given request.
+// A nil url and nil error means the request is sent without a proxy.
+type ProxyFunc func(req *http.Request) (*url.URL, error)
+
+// PacEvaluator evaluates a proxy auto-config (PAC) script.
+// FindProxyForURL has the same semantics as the FindProxyForURL function of a PAC file
+// and returns its raw result, for example "PROXY proxy.example.com:8080; DIRECT".
+// The library does not ship a javascript engine, plug in the one of your choice.
+type PacEvaluator interface {
+	FindProxyForURL(url string, host string) (string, error)
+}
+
+// ProxyFuncFromEnvironment returns a ProxyFunc which uses the proxies configured in the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables (or the lowercase versions thereof).
+// The environment is read once when this function is called.
+// Requests to localhost and loopback addresses are never proxied.
+func ProxyFuncFromEnvironment() ProxyFunc {
+	proxyForURL := httpproxy.FromEnvironment().ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyForURL(req.URL)
+	}
+}
+
+// ProxyFuncFromPac returns a ProxyFunc which asks the given PacEvaluator for the proxy of each request.
+// A ProxyFunc has one proxy per request, so it returns the first entry of the PAC result.
+// WithProxyPac tries the following entries as well if the first one cannot be reached.
+func ProxyFuncFromPac(evaluator PacEvaluator) ProxyFunc {
+	proxies := pacProxies(evaluator)
+
+	return func(req *http.Request) (*url.URL, error) {
+		proxyUrls, err := proxies(req)
+		if err != nil {
+			return nil, err
+		}
+
+		return proxyUrls[0], nil
+	}
+}
+
+// proxyListFunc returns the proxies for a request in the order they are tried, a nil url is a direct connection.
+type proxyListFunc func(req *http.Request) ([]*url.URL, error)
+
+func (f ProxyFunc) list() proxyListFunc {
+	if f == nil {
+		return nil
+	}
+
+	return func(req *http.Request) ([]*url.URL, error) {
+		proxyUrl, err := f(req)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*url.URL{proxyUrl}, nil
+	}
+}
+
+func pacProxies(evaluator PacEvaluator) proxyListFunc {
+	return func(req *http.Request) ([]*url.URL, error) {
+		result, err := evaluator.FindProxyForURL(req.URL.String(), req.URL.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate pac script: %w", err)
+		}
+
+		return parsePacResult(result)
+	}
+}
+
+// parsePacResult returns the entries of a PAC result, an empty result is a direct connection.
+func parsePacResult(result string) ([]*url.URL, error) {
+	var proxyUrls []*url.URL
+
+	for _, entry := range strings.Split(result, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		proxyUrl, err := parsePacEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		proxyUrls = append(proxyUrls, proxyUrl)
+	}
+
+	if len(proxyUrls) == 0 {
+		return []*url.URL{nil}, nil
+	}
+
+	return proxyUrls, nil
+}
+
+func parsePacEntry(entry string) (*url.URL, error) {
+	fields := strings.Fields(entry)
+
+	if strings.ToUpper(fields[0]) == "DIRECT" {
+		return nil, nil
+	}
+
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid pac result entry: %s", entry)
+	}
+
+	var scheme string
+	switch strings.ToUpper(fields[0]) {
+	case "PROXY", "HTTP":
+		scheme = "http"
+	case "HTTPS":
+		scheme = "https"
+	case "SOCKS", "SOCKS5":
+		scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported pac result type: %s", fields[0])
+	}
+
+	if _, _, err := net.SplitHostPort(fields[1]); err != nil {
+		return nil, fmt.Errorf("invalid pac proxy address %s: %w", fields[1], err)
+	}
+
+	return &url.URL{Scheme: scheme, Host: fields[1]}, nil
+}
+
+// proxySelector resolves the dialers for a request when the proxy is chosen per destination.
+// Dialers are cached per proxy url so that proxy connections (e.g. reused h2 proxy connections) are shared.
+type proxySelector struct {
+	proxies  proxyListFunc
+	timeouts Timeouts
+
+	dialersLck sync.Mutex
+	dialers    map[string]proxy.ContextDialer
+}
+
+// proxyRoute is a way to the destination, an empty proxy url means a direct connection.
+type proxyRoute struct {
+	proxyUrl string
+	dialer   proxy.ContextDialer
+}
+
+func newProxySelector(proxies proxyListFunc, timeouts Timeouts) *proxySelector {
+	return &proxySelector{
+		proxies:  proxies,
+		timeouts: timeouts,
+		dialers:  make(map[string]proxy.ContextDialer),
+	}
+}
+
+// routesFor returns the routes for the request in the order they are tried.
+func (s *proxySelector) routesFor(req *http.Request) ([]proxyRoute, error) {
+	proxyUrls, err := s.proxies(req)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]proxyRoute, 0, len(proxyUrls))
+	for _, proxyUrl := range proxyUrls {
+		key := ""
+		if proxyUrl != nil {
+			key = proxyUrl.String()
+		}
+
+		dialer, err := s.dialer(key)
+		if err != nil {
+			return nil, err
+		}
+
+		routes = append(routes, proxyRoute{proxyUrl: key, dialer: dialer})
+	}
+
+	return routes, nil
+}
+
+func (s *proxySelector) dialer(proxyUrl string) (proxy.ContextDialer, error) {
+	s.dialersLck.Lock()
+	defer s.dialersLck.Unlock()
+
+	if dialer, ok := s.dialers[proxyUrl]; ok {
+		return dialer, nil
+	}
+
+	var dialer proxy.ContextDialer
+	if proxyUrl == "" {
+		dialer = newDirectDialer(s.timeouts.Dial)
+	} else {
+		var err error
+		dialer, err = newConnectDialer(proxyUrl, s.timeouts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.dialers[proxyUrl] = dialer
+
+	return dialer, nil
+}
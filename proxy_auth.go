@@ -0,0 +1,427 @@
+package tls_client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+const maxProxyAuthAttempts = 4
+
+// proxyAuthenticator answers the Proxy-Authenticate challenges of one CONNECT handshake.
+// Basic and Digest can be answered on any connection, NTLM needs the connection to be kept open
+// between the negotiate and the authenticate message.
+type proxyAuthenticator struct {
+	username string
+	password string
+	domain   string
+
+	attempts    int
+	tried       map[string]bool
+	ntlmStarted bool
+}
+
+func newProxyAuthenticator(user *url.Userinfo) *proxyAuthenticator {
+	a := &proxyAuthenticator{
+		tried: make(map[string]bool),
+	}
+
+	if user == nil {
+		return a
+	}
+
+	a.username = user.Username()
+	a.password, _ = user.Password()
+
+	// NTLM users are often given as DOMAIN\user
+	if parts := strings.SplitN(a.username, "\\", 2); len(parts) == 2 {
+		a.domain = parts[0]
+		a.username = parts[1]
+	}
+
+	return a
+}
+
+func (a *proxyAuthenticator) hasCredentials() bool {
+	return a.username != ""
+}
+
+// markPreemptiveBasic records that the Basic credentials were already sent with the first request.
+func (a *proxyAuthenticator) markPreemptiveBasic() {
+	a.tried["basic"] = true
+}
+
+// ntlmInProgress reports whether the next request has to be sent on the same connection.
+func (a *proxyAuthenticator) ntlmInProgress() bool {
+	return a.ntlmStarted
+}
+
+// authorize returns the Proxy-Authorization header value answering the given challenges.
+// connectionBased has to be false when the next request can not be sent on the same connection (e.g. HTTP/2 proxies),
+// NTLM is not offered in that case.
+func (a *proxyAuthenticator) authorize(proxyAuthenticate []string, method string, uri string, connectionBased bool) (string, error) {
+	if !a.hasCredentials() {
+		return "", errors.New("proxy requires authentication but no credentials were provided")
+	}
+
+	a.attempts++
+	if a.attempts > maxProxyAuthAttempts {
+		return "", errors.New("proxy authentication did not succeed")
+	}
+
+	challenges := parseAuthChallenges(proxyAuthenticate)
+
+	if a.ntlmStarted {
+		a.ntlmStarted = false
+
+		challenge, ok := challenges["ntlm"]
+		if !ok || challenge.token == "" {
+			return "", errors.New("proxy rejected the ntlm negotiate message")
+		}
+
+		serverMessage, err := base64.StdEncoding.DecodeString(challenge.token)
+		if err != nil {
+			return "", fmt.Errorf("invalid ntlm challenge: %w", err)
+		}
+
+		authenticateMessage, err := ntlmAuthenticateMessage(serverMessage, a.username, a.password, a.domain)
+		if err != nil {
+			return "", err
+		}
+
+		return "NTLM " + base64.StdEncoding.EncodeToString(authenticateMessage), nil
+	}
+
+	if _, ok := challenges["ntlm"]; ok && connectionBased && !a.tried["ntlm"] {
+		a.tried["ntlm"] = true
+		a.ntlmStarted = true
+
+		return "NTLM " + base64.StdEncoding.EncodeToString(ntlmNegotiateMessage()), nil
+	}
+
+	if challenge, ok := challenges["digest"]; ok && (!a.tried["digest"] || strings.EqualFold(challenge.params["stale"], "true")) {
+		a.tried["digest"] = true
+
+		return digestAuthorization(challenge.params, a.qualifiedUsername(), a.password, method, uri)
+	}
+
+	if _, ok := challenges["basic"]; ok && !a.tried["basic"] {
+		a.tried["basic"] = true
+
+		return basicAuthorization(a.qualifiedUsername(), a.password), nil
+	}
+
+	return "", errors.New("proxy rejected the provided credentials")
+}
+
+func (a *proxyAuthenticator) qualifiedUsername() string {
+	if a.domain == "" {
+		return a.username
+	}
+
+	return a.domain + "\\" + a.username
+}
+
+type authChallenge struct {
+	token  string
+	params map[string]string
+}
+
+// parseAuthChallenges parses Proxy-Authenticate header values keyed by the lower case scheme.
+// Proxies send one challenge per header line, multiple challenges in one line are not supported.
+func parseAuthChallenges(values []string) map[string]authChallenge {
+	challenges := make(map[string]authChallenge)
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		scheme, rest := value, ""
+
+		if i := strings.IndexByte(value, ' '); i >= 0 {
+			scheme, rest = value[:i], strings.TrimSpace(value[i+1:])
+		}
+
+		challenge := authChallenge{params: make(map[string]string)}
+
+		// tokens like the ones of NTLM are base64 encoded and only contain '=' as padding
+		if strings.Contains(strings.TrimRight(rest, "="), "=") {
+			challenge.params = parseAuthParams(rest)
+		} else {
+			challenge.token = rest
+		}
+
+		challenges[strings.ToLower(scheme)] = challenge
+	}
+
+	return challenges
+}
+
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimSpace(s[eq+1:])
+
+		var value string
+		if strings.HasPrefix(s, "\"") {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+
+		params[key] = value
+	}
+
+	return params
+}
+
+func basicAuthorization(username string, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func digestAuthorization(params map[string]string, username string, password string, method string, uri string) (string, error) {
+	algorithm := params["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm: %s", algorithm)
+	}
+
+	h := func(s string) string {
+		d := newHash()
+		d.Write([]byte(s))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	realm := params["realm"]
+	nonce := params["nonce"]
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := "00000001"
+
+	ha1 := h(username + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	qop := ""
+	for _, q := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	var response string
+	if qop != "" {
+		response = h(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, response="%s"`, username, realm, nonce, uri, algorithm, response)
+
+	if qop != "" {
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+
+	if opaque, ok := params["opaque"]; ok {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+
+	return authorization, nil
+}
+
+const (
+	ntlmNegotiateUnicode            = 0x00000001
+	ntlmNegotiateOEM                = 0x00000002
+	ntlmRequestTarget               = 0x00000004
+	ntlmNegotiateNTLM               = 0x00000200
+	ntlmNegotiateAlwaysSign         = 0x00008000
+	ntlmNegotiateExtendedSession    = 0x00080000
+	ntlmNegotiateTargetInfo         = 0x00800000
+	ntlmNegotiate128                = 0x20000000
+	ntlmNegotiate56                 = 0x80000000
+	ntlmAvIdTimestamp               = 7
+	ntlmAuthenticateMessageHdrBytes = 64
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmNegotiateUnicode|ntlmNegotiateOEM|ntlmRequestTarget|ntlmNegotiateNTLM|
+		ntlmNegotiateAlwaysSign|ntlmNegotiateExtendedSession|ntlmNegotiateTargetInfo|ntlmNegotiate128|ntlmNegotiate56)
+
+	return msg
+}
+
+// ntlmAuthenticateMessage builds the NTLMv2 authenticate message answering the challenge message of the server.
+func ntlmAuthenticateMessage(challengeMessage []byte, username string, password string, domain string) ([]byte, error) {
+	if len(challengeMessage) < 32 || !bytes.Equal(challengeMessage[:8], ntlmSignature) || binary.LittleEndian.Uint32(challengeMessage[8:]) != 2 {
+		return nil, errors.New("invalid ntlm challenge message")
+	}
+
+	flags := binary.LittleEndian.Uint32(challengeMessage[20:])
+	serverChallenge := challengeMessage[24:32]
+
+	var targetInfo []byte
+	if len(challengeMessage) >= 48 {
+		length := int(binary.LittleEndian.Uint16(challengeMessage[40:]))
+		offset := int(binary.LittleEndian.Uint32(challengeMessage[44:]))
+		if offset+length > len(challengeMessage) {
+			return nil, errors.New("invalid ntlm target info")
+		}
+		targetInfo = challengeMessage[offset : offset+length]
+	}
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+
+	timestamp := ntlmTimestamp(targetInfo)
+	if timestamp == nil {
+		timestamp = make([]byte, 8)
+		// windows file time: 100 nanosecond intervals since 1601-01-01
+		binary.LittleEndian.PutUint64(timestamp, uint64(time.Now().UnixNano()/100+116444736000000000))
+	}
+
+	ntResponse, lmResponse := ntlmV2Responses(username, password, domain, serverChallenge, clientChallenge, timestamp, targetInfo)
+
+	encode := func(s string) []byte {
+		if flags&ntlmNegotiateUnicode != 0 {
+			return utf16le(s)
+		}
+		return []byte(s)
+	}
+
+	payloads := [][]byte{lmResponse, ntResponse, encode(domain), encode(username), encode(""), {}}
+
+	msg := make([]byte, ntlmAuthenticateMessageHdrBytes)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+
+	offset := ntlmAuthenticateMessageHdrBytes
+	for i, payload := range payloads {
+		field := msg[12+i*8:]
+		binary.LittleEndian.PutUint16(field[0:], uint16(len(payload)))
+		binary.LittleEndian.PutUint16(field[2:], uint16(len(payload)))
+		binary.LittleEndian.PutUint32(field[4:], uint32(offset))
+		offset += len(payload)
+	}
+
+	binary.LittleEndian.PutUint32(msg[60:], flags&^ntlmNegotiateOEM)
+
+	for _, payload := range payloads {
+		msg = append(msg, payload...)
+	}
+
+	return msg, nil
+}
+
+func ntlmV2Responses(username string, password string, domain string, serverChallenge []byte, clientChallenge []byte, timestamp []byte, targetInfo []byte) ([]byte, []byte) {
+	passwordHash := md4.New()
+	passwordHash.Write(utf16le(password))
+
+	ntowf := hmacMd5(passwordHash.Sum(nil), utf16le(strings.ToUpper(username)+domain))
+
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	ntProof := hmacMd5(ntowf, append(append([]byte{}, serverChallenge...), temp...))
+	lmProof := hmacMd5(ntowf, append(append([]byte{}, serverChallenge...), clientChallenge...))
+
+	return append(ntProof, temp...), append(lmProof, clientChallenge...)
+}
+
+func ntlmTimestamp(targetInfo []byte) []byte {
+	for len(targetInfo) >= 4 {
+		id := binary.LittleEndian.Uint16(targetInfo[0:])
+		length := int(binary.LittleEndian.Uint16(targetInfo[2:]))
+
+		if len(targetInfo) < 4+length || id == 0 {
+			return nil
+		}
+
+		if id == ntlmAvIdTimestamp && length == 8 {
+			return targetInfo[4:12]
+		}
+
+		targetInfo = targetInfo[4+length:]
+	}
+
+	return nil
+}
+
+func hmacMd5(key []byte, data []byte) []byte {
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func utf16le(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(encoded))
+
+	for i, r := range encoded {
+		binary.LittleEndian.PutUint16(b[2*i:], r)
+	}
+
+	return b
+}
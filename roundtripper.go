@@ -16,11 +16,10 @@ import (
 	utls "github.com/bogdanfinn/utls"
 )
 
-var errProtocolNegotiated = errors.New("protocol negotiated")
-
 type roundTripper struct {
-	sync.Mutex
 	transportOptions    *TransportOptions
+	timeouts            Timeouts
+	h2HealthCheck       H2HealthCheck
 	serverNameOverwrite string
 	clientHelloId       utls.ClientHelloID
 	settings            map[http2.SettingID]uint32
@@ -28,177 +27,355 @@ type roundTripper struct {
 	priorities          []http2.Priority
 	pseudoHeaderOrder   []string
 	connectionFlow      uint32
+	profileKey          string
 
 	insecureSkipVerify          bool
 	withRandomTlsExtensionOrder bool
 
+	// cachedTransportsLck guards the pools and the proxy configuration below.
+	// It is never held while dialing, so handshakes to different addresses run in parallel.
 	cachedTransportsLck sync.Mutex
-	cachedConnections   map[string]net.Conn
-	cachedTransports    map[string]http.RoundTripper
+	pools               map[string]*connectionPool
+	keepPoolsWarm       bool
 
 	forceHttp1 bool
-
-	dialer proxy.ContextDialer
+	h2cMode    H2CMode
+	// clientTrace is used for requests without a trace in their context
+	clientTrace *ClientTrace
+	// alpnProtocols are the protocols offered by the client hello, nil if the ones of the profile are used
+	alpnProtocols []string
+
+	proxyGeneration uint64
+	proxyUrl        string
+	dialer          proxy.ContextDialer
+	proxySelector   *proxySelector
 }
 
-func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	addr := rt.getDialTLSAddr(req)
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
 
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	rt.cachedTransportsLck.Lock()
+	generation, proxyUrl, dialer, selector := rt.proxyGeneration, rt.proxyUrl, rt.dialer, rt.proxySelector
+	rt.cachedTransportsLck.Unlock()
 
-	if _, ok := rt.cachedTransports[addr]; !ok {
-		if err := rt.getTransport(req, addr); err != nil {
-			rt.cachedTransportsLck.Unlock()
-			return nil, err
+	routes := []proxyRoute{{proxyUrl: proxyUrl, dialer: dialer}}
+	if selector != nil {
+		var err error
+		routes, err = selector.routesFor(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select proxy: %w", err)
 		}
 	}
 
-	t := rt.cachedTransports[addr]
+	for i, route := range routes {
+		resp, sent, err := rt.roundTripRoute(req, generation, route)
+		if errors.Is(err, errProxySwitched) {
+			// the proxy was switched in the meantime, start over with the new configuration
+			return rt.RoundTrip(req)
+		}
+
+		// like browsers do with the entries of a PAC result, the next route is tried if this one could not be reached
+		var dialErr *DialError
+		if err == nil || i == len(routes)-1 || !errors.As(err, &dialErr) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if sent && req.Body != nil && req.Body != http.NoBody {
+			// the transport may have read the body already
+			if req.GetBody == nil {
+				return nil, err
+			}
+
+			body, getBodyErr := req.GetBody()
+			if getBodyErr != nil {
+				return nil, err
+			}
+
+			req = req.WithContext(req.Context())
+			req.Body = body
+		}
+	}
+
+	return nil, errors.New("no proxy route")
+}
+
+var errProxySwitched = errors.New("proxy switched")
+
+// roundTripRoute sends the request on the route, sent reports whether the request was handed to a transport.
+func (rt *roundTripper) roundTripRoute(req *http.Request, generation uint64, route proxyRoute) (*http.Response, bool, error) {
+	rt.cachedTransportsLck.Lock()
+
+	if generation != rt.proxyGeneration {
+		rt.cachedTransportsLck.Unlock()
+		return nil, false, errProxySwitched
+	}
+
+	// counting the request before the negotiation keeps the pool from being closed while dialing
+	pool := rt.getPool(route.proxyUrl)
+	pool.inFlight++
+	pool.retired = false
 	rt.cachedTransportsLck.Unlock()
 
-	return t.RoundTrip(req)
+	req = withClientTrace(req, rt.clientTrace)
+	req, fillConnectionInfo := withConnectionInfo(req)
+
+	t, err := rt.getTransport(req, rt.getConnectionKey(req, route.proxyUrl), pool, route.dialer)
+	if err != nil {
+		rt.requestFinished(pool)
+		return nil, false, err
+	}
+
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		rt.requestFinished(pool)
+		return nil, true, rt.responseHeaderTimeoutError(req, err)
+	}
+
+	fillConnectionInfo(resp)
+
+	resp.Body = newTrackedBody(resp.Body, func() {
+		rt.requestFinished(pool)
+	})
+
+	return resp, true, nil
 }
 
-func (rt *roundTripper) getTransport(req *http.Request, addr string) error {
-	switch strings.ToLower(req.URL.Scheme) {
+// getTransport returns the transport of the key and negotiates it if there is none yet.
+// Concurrent requests with the same key wait for the first negotiation instead of dialing on their own.
+func (rt *roundTripper) getTransport(req *http.Request, key connectionKey, pool *connectionPool, dialer proxy.ContextDialer) (http.RoundTripper, error) {
+	for {
+		rt.cachedTransportsLck.Lock()
+
+		if t, ok := pool.transports[key]; ok {
+			rt.cachedTransportsLck.Unlock()
+			return t, nil
+		}
+
+		if n, ok := pool.negotiations[key]; ok {
+			rt.cachedTransportsLck.Unlock()
+
+			select {
+			case <-n.done:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+
+			// the request which negotiated was canceled, this one is still alive and negotiates again
+			if n.err != nil && !n.canceled {
+				return nil, n.err
+			}
+
+			continue
+		}
+
+		n := &negotiation{done: make(chan struct{})}
+		pool.negotiations[key] = n
+		rt.cachedTransportsLck.Unlock()
+
+		t, conn, err := rt.negotiate(req.Context(), key, pool, dialer)
+
+		rt.cachedTransportsLck.Lock()
+		delete(pool.negotiations, key)
+		if err == nil {
+			pool.transports[key] = t
+			if conn != nil {
+				// Stash the connection just established for use servicing the
+				// actual request (should be near-immediate).
+				pool.connections[key] = conn
+			}
+		}
+		n.err = err
+		n.canceled = err != nil && req.Context().Err() != nil
+		close(n.done)
+		rt.cachedTransportsLck.Unlock()
+
+		return t, err
+	}
+}
+
+// negotiate builds the transport of the key. For https the first connection is dialed to learn the protocol via ALPN.
+func (rt *roundTripper) negotiate(ctx context.Context, key connectionKey, pool *connectionPool, dialer proxy.ContextDialer) (http.RoundTripper, net.Conn, error) {
+	switch key.scheme {
 	case "http":
-		rt.cachedTransports[addr] = rt.buildHttp1Transport()
-		return nil
+		return rt.negotiateCleartext(key, pool, dialer)
 	case "https":
 	default:
-		return fmt.Errorf("invalid URL scheme: [%v]", req.URL.Scheme)
+		return nil, nil, fmt.Errorf("%w: [%v]", ErrInvalidURLScheme, key.scheme)
 	}
 
-	_, err := rt.dialTLS(context.Background(), "tcp", addr)
-	switch err {
-	case errProtocolNegotiated:
-	case nil:
-		// Should never happen.
-		panic("dialTLS returned no error when determining cachedTransports")
-	default:
-		return err
+	conn, err := rt.handshake(ctx, dialer, "tcp", key.addr, key.serverName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Create the transport based on the results of ALPN if no http1 is enforced.
+	if rt.forceHttp1 || conn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		return rt.buildHttp1Transport(key, pool, dialer), newInfoConn(conn, conn, key.proxyUrl, clientTraceFrom(ctx)), nil
+	}
+
+	return rt.buildHttp2Transport(key, pool, dialer), newInfoConn(conn, conn, key.proxyUrl, clientTraceFrom(ctx)), nil
+}
+
+// negotiateCleartext builds the transport of a http:// key, which is HTTP/2 only if h2c is enabled.
+func (rt *roundTripper) negotiateCleartext(key connectionKey, pool *connectionPool, dialer proxy.ContextDialer) (http.RoundTripper, net.Conn, error) {
+	if rt.forceHttp1 || rt.h2cMode == H2CDisabled {
+		return rt.buildHttp1Transport(key, pool, dialer), nil, nil
+	}
+
+	t2 := rt.buildHttp2Transport(key, pool, dialer)
+	connPool := newH2CConnPool(rt.buildHttp2Transport(key, pool, dialer), rt.dialCleartextVia(key, dialer))
+
+	if rt.h2cMode == H2CPriorKnowledge {
+		return newH2CTransport(t2, connPool), nil, nil
 	}
 
-	return nil
+	return newH2CUpgradeTransport(t2, connPool, key.addr, dialer.DialContext, key.proxyUrl, rt.buildHttp1Transport(key, pool, dialer)), nil, nil
 }
 
-func (rt *roundTripper) dialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
-	rt.Lock()
-	defer rt.Unlock()
+// dialTLSVia binds dialTLS to the key, pool and dialer of one transport.
+func (rt *roundTripper) dialTLSVia(key connectionKey, pool *connectionPool, dialer proxy.ContextDialer) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return rt.dialTLS(ctx, key, pool, dialer, network, addr)
+	}
+}
 
+func (rt *roundTripper) dialTLS(ctx context.Context, key connectionKey, pool *connectionPool, dialer proxy.ContextDialer, network, addr string) (net.Conn, error) {
 	// If we have the connection from when we determined the HTTPS
 	// cachedTransports to use, return that.
-	if conn := rt.cachedConnections[addr]; conn != nil {
-		delete(rt.cachedConnections, addr)
+	rt.cachedTransportsLck.Lock()
+	conn := pool.connections[key]
+	delete(pool.connections, key)
+	rt.cachedTransportsLck.Unlock()
+
+	if conn != nil {
 		return conn, nil
 	}
 
-	rawConn, err := rt.dialer.DialContext(ctx, network, addr)
+	tlsConn, err := rt.handshake(ctx, dialer, network, addr, key.serverName)
 	if err != nil {
 		return nil, err
 	}
 
-	var host string
-	if host, _, err = net.SplitHostPort(addr); err != nil {
-		host = addr
+	return newInfoConn(tlsConn, tlsConn, key.proxyUrl, clientTraceFrom(ctx)), nil
+}
+
+// dialCleartextVia dials connections without tls for the transports of the key.
+func (rt *roundTripper) dialCleartextVia(key connectionKey, dialer proxy.ContextDialer) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return newInfoConn(conn, nil, key.proxyUrl, clientTraceFrom(ctx)), nil
 	}
+}
 
-	conn := utls.UClient(rawConn, &utls.Config{ServerName: host, InsecureSkipVerify: rt.insecureSkipVerify}, rt.clientHelloId, rt.withRandomTlsExtensionOrder)
-	if err = conn.Handshake(); err != nil {
-		_ = conn.Close()
+func (rt *roundTripper) handshake(ctx context.Context, dialer proxy.ContextDialer, network, addr string, serverName string) (*utls.UConn, error) {
+	rawConn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
 		return nil, err
 	}
 
-	if rt.cachedTransports[addr] != nil {
-		return conn, nil
+	conn := utls.UClient(rawConn, &utls.Config{ServerName: serverName, InsecureSkipVerify: rt.insecureSkipVerify}, rt.clientHelloId, rt.withRandomTlsExtensionOrder)
+
+	trace := clientTraceFrom(ctx)
+	trace.tlsHandshakeStart(serverName, conn)
+	err = handshakeWithTimeout(ctx, rt.timeouts.TLSHandshake, conn.HandshakeContext)
+	trace.tlsHandshakeDone(conn.ConnectionState(), err)
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, newTLSHandshakeError(ctx, serverName, err)
 	}
 
-	// No http.Transport constructed yet, create one based on the results
-	// of ALPN if no http1 is enforced.
+	if err = checkNegotiatedProtocol(conn.ConnectionState().NegotiatedProtocol, rt.alpnProtocols, rt.forceHttp1); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
 
-	if rt.forceHttp1 {
-		rt.cachedTransports[addr] = rt.buildHttp1Transport()
-	} else {
+	return conn, nil
+}
 
-		switch conn.ConnectionState().NegotiatedProtocol {
-		case http2.NextProtoTLS:
-			utlsConfig := &utls.Config{InsecureSkipVerify: rt.insecureSkipVerify}
+func (rt *roundTripper) buildHttp2Transport(key connectionKey, pool *connectionPool, dialer proxy.ContextDialer) *http2.Transport {
+	utlsConfig := &utls.Config{InsecureSkipVerify: rt.insecureSkipVerify}
 
-			if rt.serverNameOverwrite != "" {
-				utlsConfig.ServerName = rt.serverNameOverwrite
-			}
+	if rt.serverNameOverwrite != "" {
+		utlsConfig.ServerName = rt.serverNameOverwrite
+	}
 
-			t2 := http2.Transport{DialTLS: rt.dialTLSHTTP2, TLSClientConfig: utlsConfig, ConnectionFlow: rt.connectionFlow}
-
-			if rt.transportOptions != nil {
-				t1 := t2.GetT1()
-				if t1 != nil {
-					t1.DisableKeepAlives = rt.transportOptions.DisableKeepAlives
-					t1.DisableCompression = rt.transportOptions.DisableCompression
-					t1.MaxIdleConns = rt.transportOptions.MaxIdleConns
-					t1.MaxIdleConnsPerHost = rt.transportOptions.MaxIdleConnsPerHost
-					t1.MaxConnsPerHost = rt.transportOptions.MaxConnsPerHost
-					t1.MaxResponseHeaderBytes = rt.transportOptions.MaxResponseHeaderBytes
-					t1.WriteBufferSize = rt.transportOptions.WriteBufferSize
-					t1.ReadBufferSize = rt.transportOptions.ReadBufferSize
-				}
-			}
+	dialTLS := rt.dialTLSVia(key, pool, dialer)
+	// the http2 transport dials on its own without the context of a request, only the trace of the client applies
+	dialTLSHTTP2 := func(network, addr string, _ *utls.Config) (net.Conn, error) {
+		ctx := context.Background()
+		if rt.clientTrace != nil {
+			ctx = context.WithValue(ctx, ContextKeyClientTrace{}, rt.clientTrace)
+		}
 
-			if rt.pseudoHeaderOrder == nil {
-				t2.PseudoHeaderOrder = []string{}
-			} else {
-				t2.PseudoHeaderOrder = rt.pseudoHeaderOrder
-			}
+		return dialTLS(ctx, network, addr)
+	}
 
-			if rt.settings == nil {
-				// when we not provide a map of custom http2 settings
-				t2.Settings = map[http2.SettingID]uint32{
-					http2.SettingMaxConcurrentStreams: 1000,
-					http2.SettingMaxFrameSize:         16384,
-					http2.SettingInitialWindowSize:    6291456,
-					http2.SettingHeaderTableSize:      65536,
-				}
-
-				keys := make([]http2.SettingID, len(t2.Settings))
-
-				i := 0
-				// attention: the order might be random here for default values!
-				for k := range t2.Settings {
-					keys[i] = k
-					i++
-				}
-
-				t2.SettingsOrder = keys
-			} else {
-				// use custom http2 settings
-				t2.Settings = rt.settings
-				t2.SettingsOrder = rt.settingsOrder
-			}
+	// the http1 transport carries the options the http2 transport has no fields for, e.g. the idle and response header timeouts
+	t1 := &http.Transport{
+		ResponseHeaderTimeout: rt.timeouts.ResponseHeader,
+		IdleConnTimeout:       rt.timeouts.IdleConnection,
+	}
+
+	t2, err := http2.ConfigureTransports(t1)
+	if err != nil {
+		// Should never happen, t1 is a fresh transport.
+		panic(err)
+	}
 
-			t2.Priorities = rt.priorities
+	// the pool of ConfigureTransports only uses connections handed over by t1, the default pool dials itself
+	t2.ConnPool = nil
+	t2.DialTLS = dialTLSHTTP2
+	t2.TLSClientConfig = utlsConfig
+	t2.ConnectionFlow = rt.connectionFlow
+	// the transport replaces connections after a GOAWAY or a lost ping on its own and retries requests the server did not process
+	t2.ReadIdleTimeout = rt.h2HealthCheck.ReadIdleTimeout
+	t2.PingTimeout = rt.h2HealthCheck.PingTimeout
 
-			t2.PushHandler = &http2.DefaultPushHandler{}
-			rt.cachedTransports[addr] = &t2
-		default:
-			rt.cachedTransports[addr] = rt.buildHttp1Transport()
+	if rt.transportOptions != nil {
+		t1 := t2.GetT1()
+		if t1 != nil {
+			t1.DisableKeepAlives = rt.transportOptions.DisableKeepAlives
+			t1.DisableCompression = rt.transportOptions.DisableCompression
+			t1.MaxIdleConns = rt.transportOptions.MaxIdleConns
+			t1.MaxIdleConnsPerHost = rt.transportOptions.MaxIdleConnsPerHost
+			t1.MaxConnsPerHost = rt.transportOptions.MaxConnsPerHost
+			t1.MaxResponseHeaderBytes = rt.transportOptions.MaxResponseHeaderBytes
+			t1.WriteBufferSize = rt.transportOptions.WriteBufferSize
+			t1.ReadBufferSize = rt.transportOptions.ReadBufferSize
 		}
 	}
 
-	// Stash the connection just established for use servicing the
-	// actual request (should be near-immediate).
-	rt.cachedConnections[addr] = conn
+	if rt.pseudoHeaderOrder == nil {
+		t2.PseudoHeaderOrder = []string{}
+	} else {
+		t2.PseudoHeaderOrder = rt.pseudoHeaderOrder
+	}
+
+	t2.Settings = rt.settings
+	t2.SettingsOrder = rt.settingsOrder
+
+	t2.Priorities = rt.priorities
+
+	t2.PushHandler = &http2.DefaultPushHandler{}
 
-	return nil, errProtocolNegotiated
+	return t2
 }
 
-func (rt *roundTripper) buildHttp1Transport() *http.Transport {
+func (rt *roundTripper) buildHttp1Transport(key connectionKey, pool *connectionPool, dialer proxy.ContextDialer) *http.Transport {
 	utlsConfig := &utls.Config{InsecureSkipVerify: rt.insecureSkipVerify}
 
 	if rt.serverNameOverwrite != "" {
 		utlsConfig.ServerName = rt.serverNameOverwrite
 	}
 
-	t := &http.Transport{DialTLSContext: rt.dialTLS, TLSClientConfig: utlsConfig, ConnectionFlow: rt.connectionFlow}
+	t := &http.Transport{DialContext: rt.dialCleartextVia(key, dialer), DialTLSContext: rt.dialTLSVia(key, pool, dialer), TLSClientConfig: utlsConfig, ConnectionFlow: rt.connectionFlow}
+	t.ResponseHeaderTimeout = rt.timeouts.ResponseHeader
+	t.IdleConnTimeout = rt.timeouts.IdleConnection
 
 	if rt.transportOptions != nil {
 		t.DisableKeepAlives = rt.transportOptions.DisableKeepAlives
@@ -214,41 +391,71 @@ func (rt *roundTripper) buildHttp1Transport() *http.Transport {
 	return t
 }
 
-func (rt *roundTripper) dialTLSHTTP2(network, addr string, _ *utls.Config) (net.Conn, error) {
-	return rt.dialTLS(context.Background(), network, addr)
+// responseHeaderTimeoutError marks errors caused by the response header timeout, which both transports report as plain timeouts.
+func (rt *roundTripper) responseHeaderTimeoutError(req *http.Request, err error) error {
+	var timeoutErr *TimeoutError
+	if rt.timeouts.ResponseHeader <= 0 || req.Context().Err() != nil || !isTimeout(err) || errors.As(err, &timeoutErr) {
+		return err
+	}
+
+	return &TimeoutError{Phase: TimeoutPhaseResponseHeader, Err: err}
 }
 
-func (rt *roundTripper) getDialTLSAddr(req *http.Request) string {
-	host, port, err := net.SplitHostPort(req.URL.Host)
-	if err == nil {
-		return net.JoinHostPort(host, port)
+// getConnectionKey determines which connections the request may use.
+func (rt *roundTripper) getConnectionKey(req *http.Request, proxyUrl string) connectionKey {
+	scheme := strings.ToLower(req.URL.Scheme)
+
+	// Hostname and Port strip the brackets of IPv6 literals, JoinHostPort adds them again
+	host := req.URL.Hostname()
+	port := req.URL.Port()
+	if port == "" {
+		port = "443"
+		if scheme == "http" {
+			port = "80"
+		}
+	}
+
+	serverName := host
+	if rt.serverNameOverwrite != "" {
+		serverName = rt.serverNameOverwrite
+	}
+
+	return connectionKey{
+		scheme:     scheme,
+		addr:       net.JoinHostPort(host, port),
+		serverName: serverName,
+		proxyUrl:   proxyUrl,
+		profile:    rt.profileKey,
 	}
-	return net.JoinHostPort(req.URL.Host, "443") // we can assume port is 443 at this point
 }
 
-func newRoundTripper(clientProfile ClientProfile, transportOptions *TransportOptions, serverNameOverwrite string, insecureSkipVerify bool, withRandomTlsExtensionOrder bool, forceHttp1 bool, dialer ...proxy.ContextDialer) http.RoundTripper {
-	rt := &roundTripper{
-		dialer:                      dialer[0],
+func newRoundTripper(clientProfile ClientProfile, transportOptions *TransportOptions, timeouts Timeouts, h2HealthCheck H2HealthCheck, serverNameOverwrite string, insecureSkipVerify bool, withRandomTlsExtensionOrder bool, forceHttp1 bool, h2cMode H2CMode, alpnProtocols []string, keepPoolsWarm bool, clientTrace *ClientTrace) *roundTripper {
+	settings, settingsOrder := clientProfile.settings, clientProfile.settingsOrder
+	if settings == nil {
+		// profiles without http2 settings send the defaults, which are copied so later overrides do not affect this client
+		settings, settingsOrder = DefaultH2Settings.copy()
+	}
+
+	return &roundTripper{
+		dialer:                      proxy.Direct,
 		transportOptions:            transportOptions,
+		timeouts:                    timeouts,
+		h2HealthCheck:               h2HealthCheck,
 		serverNameOverwrite:         serverNameOverwrite,
-		settings:                    clientProfile.settings,
-		settingsOrder:               clientProfile.settingsOrder,
+		settings:                    settings,
+		settingsOrder:               settingsOrder,
 		priorities:                  clientProfile.priorities,
 		pseudoHeaderOrder:           clientProfile.pseudoHeaderOrder,
 		insecureSkipVerify:          insecureSkipVerify,
 		forceHttp1:                  forceHttp1,
+		h2cMode:                     h2cMode,
+		clientTrace:                 clientTrace,
+		alpnProtocols:               alpnProtocols,
+		keepPoolsWarm:               keepPoolsWarm,
 		withRandomTlsExtensionOrder: withRandomTlsExtensionOrder,
 		connectionFlow:              clientProfile.connectionFlow,
 		clientHelloId:               clientProfile.clientHelloId,
-		cachedTransports:            make(map[string]http.RoundTripper),
-		cachedConnections:           make(map[string]net.Conn),
+		profileKey:                  fmt.Sprintf("%s|%t|%s", clientProfile.clientHelloId.Str(), withRandomTlsExtensionOrder, strings.Join(alpnProtocols, ",")),
+		pools:                       make(map[string]*connectionPool),
 	}
-
-	if len(dialer) > 0 {
-		rt.dialer = dialer[0]
-	} else {
-		rt.dialer = proxy.Direct
-	}
-
-	return rt
 }
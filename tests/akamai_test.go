@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAkamaiFingerprint_BrowserFingerprints(t *testing.T) {
+	compared := 0
+
+	for _, info := range tls_client.ListProfiles() {
+		profile, err := tls_client.LookupProfile(info.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id := profile.GetClientHelloId()
+		for _, fingerprints := range browserFingerprints {
+			expected, ok := fingerprints[id.Str()]
+			if !ok {
+				continue
+			}
+
+			assert.Equal(t, expected[akamaiFingerprint], profile.AkamaiFingerprint(), info.Name)
+			assert.Equal(t, expected[akamaiFingerprintHash], profile.AkamaiFingerprintHash(), info.Name)
+			compared++
+		}
+	}
+
+	assert.NotZero(t, compared)
+}
+
+func TestParseAkamaiFingerprint(t *testing.T) {
+	settings, settingsOrder, pseudoHeaderOrder, connectionFlow, priorities, err := tls_client.ParseAkamaiFingerprint("1:65536,4:131072,5:16384|12517377|3:0:0:201,5:0:0:101,7:0:0:1,9:0:7:1,11:0:3:1,13:0:0:241|m,p,a,s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, tls_client.Firefox_106.GetSettings(), settings)
+	assert.Equal(t, tls_client.Firefox_106.GetSettingsOrder(), settingsOrder)
+	assert.Equal(t, tls_client.Firefox_106.GetPseudoHeaderOrder(), pseudoHeaderOrder)
+	assert.Equal(t, tls_client.Firefox_106.GetConnectionFlow(), connectionFlow)
+	assert.Equal(t, tls_client.Firefox_106.GetPriorities(), priorities)
+}
+
+func TestParseAkamaiFingerprint_RoundTrip(t *testing.T) {
+	for _, fingerprint := range []string{
+		"1:65536,2:0,3:1000,4:6291456,6:262144|15663105|0|m,a,s,p",
+		"2:0,3:100,4:2097152|10485760|0|m,s,p,a",
+		"1:4096,9:1|0|1:1:0:256|a,m",
+		"|0|0|",
+	} {
+		settings, settingsOrder, pseudoHeaderOrder, connectionFlow, priorities, err := tls_client.ParseAkamaiFingerprint(fingerprint)
+		if err != nil {
+			t.Fatalf("%s: %v", fingerprint, err)
+		}
+
+		profile := tls_client.NewClientProfile(tls_client.Chrome_107.GetClientHelloId(), settings, settingsOrder, pseudoHeaderOrder, connectionFlow, priorities)
+
+		assert.Equal(t, fingerprint, profile.AkamaiFingerprint())
+	}
+}
+
+func TestParseAkamaiFingerprint_Weight(t *testing.T) {
+	_, _, _, _, priorities, err := tls_client.ParseAkamaiFingerprint("1:65536|15663105|3:1:0:256|m,a,s,p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []http2.Priority{{StreamID: 3, PriorityParam: http2.PriorityParam{Exclusive: true, Weight: 255}}}, priorities)
+}
+
+func TestParseAkamaiFingerprint_RejectsInvalid(t *testing.T) {
+	for _, fingerprint := range []string{
+		"",
+		"1:65536|15663105|0",
+		"1=65536|15663105|0|m,a,s,p",
+		"1:65536,1:4096|15663105|0|m,a,s,p",
+		"1:65536|flow|0|m,a,s,p",
+		"1:65536|15663105|3:0:0:0|m,a,s,p",
+		"1:65536|15663105|3:2:0:1|m,a,s,p",
+		"1:65536|15663105|0|m,a,s,x",
+	} {
+		_, _, _, _, _, err := tls_client.ParseAkamaiFingerprint(fingerprint)
+		assert.Error(t, err, fingerprint)
+	}
+}
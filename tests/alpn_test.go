@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	tls "github.com/bogdanfinn/utls"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ForceHttp1OffersOnlyHttp1(t *testing.T) {
+	testServer, offered := newALPNRecordingServer()
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify(), tls_client.WithForceHttp1())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 1, resp.ProtoMajor)
+	assert.Equal(t, [][]string{{"http/1.1"}}, offered())
+}
+
+func TestClient_CustomALPNProtocols(t *testing.T) {
+	testServer, offered := newALPNRecordingServer()
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Firefox_106), tls_client.WithInsecureSkipVerify(), tls_client.WithALPNProtocols("http/1.1", "h2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	// the server prefers its own order and selects h2
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, [][]string{{"http/1.1", "h2"}}, offered())
+}
+
+func TestClient_ForceHttp1FailsIfServerSelectsH2(t *testing.T) {
+	testServer, _ := newALPNRecordingServer()
+	defer testServer.Close()
+
+	options := []tls_client.HttpClientOption{
+		tls_client.WithClientProfile(tls_client.Chrome_107),
+		tls_client.WithInsecureSkipVerify(),
+		tls_client.WithForceHttp1(),
+		tls_client.WithALPNProtocols("h2", "http/1.1"),
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get(testServer.URL)
+
+	assert.True(t, errors.Is(err, tls_client.ErrUnexpectedProtocol))
+}
+
+func TestClient_ForceHttp1FailsForProfilesWithoutSpec(t *testing.T) {
+	profile := tls_client.NewClientProfile(tls.HelloRandomized, nil, nil, nil, 0, nil)
+
+	_, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(profile), tls_client.WithForceHttp1())
+
+	assert.ErrorContains(t, err, "failed to rewrite alpn")
+}
+
+// newALPNRecordingServer starts a http2 enabled tls server which records the protocols offered by every client hello.
+func newALPNRecordingServer() (*httptest.Server, func() [][]string) {
+	var mu sync.Mutex
+	var offered [][]string
+
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	testServer.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			offered = append(offered, hello.SupportedProtos)
+			mu.Unlock()
+
+			return nil, nil
+		},
+	}
+	testServer.EnableHTTP2 = true
+	testServer.StartTLS()
+
+	return testServer, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([][]string(nil), offered...)
+	}
+}
@@ -0,0 +1,311 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	tls "github.com/bogdanfinn/utls"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfilesFromCapture_Tls13(t *testing.T) {
+	chunks, keyLog := recordConnection(t, tls_client.Chrome_107, tls.VersionTLS13)
+
+	for _, pcapng := range []bool{false, true} {
+		profiles, err := tls_client.ProfilesFromCapture(writeCapture(chunks, pcapng), keyLog)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !assert.Len(t, profiles, 1) {
+			continue
+		}
+
+		captured := profiles[0]
+
+		assert.Equal(t, "10.0.0.1:50000", captured.Client)
+		assert.Equal(t, "10.0.0.2:443", captured.Server)
+		assert.Equal(t, "example.com", captured.ServerName)
+		assert.True(t, captured.Http2)
+		assertSameProfile(t, "captured", tls_client.Chrome_107, captured.Profile)
+
+		expected := strings.ReplaceAll(marshalClientHello(t, tls_client.Chrome_107), "name: alps", "name: application_settings")
+		assert.Equal(t, expected, marshalClientHello(t, captured.Profile))
+
+		data, err := tls_client.MarshalProfile(captured.Profile, tls_client.ProfileFormatYAML)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := tls_client.LoadProfile(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertSameProfile(t, "loaded", tls_client.Chrome_107, loaded)
+	}
+}
+
+func TestProfilesFromCapture_Tls12WithPriorities(t *testing.T) {
+	chunks, keyLog := recordConnection(t, tls_client.Firefox_106, tls.VersionTLS12)
+
+	profiles, err := tls_client.ProfilesFromCapture(writeCapture(chunks, false), keyLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, profiles, 1) {
+		return
+	}
+
+	assert.True(t, profiles[0].Http2)
+	assertSameProfile(t, "captured", tls_client.Firefox_106, profiles[0].Profile)
+}
+
+func TestProfilesFromCapture_WithoutKeyLog(t *testing.T) {
+	chunks, _ := recordConnection(t, tls_client.Chrome_107, tls.VersionTLS13)
+
+	profiles, err := tls_client.ProfilesFromCapture(writeCapture(chunks, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, profiles, 1) {
+		return
+	}
+
+	assert.False(t, profiles[0].Http2)
+	assert.Nil(t, profiles[0].Profile.GetSettings())
+	assert.Empty(t, profiles[0].Profile.GetPseudoHeaderOrder())
+}
+
+func TestProfilesFromCapture_TruncatedClientHello(t *testing.T) {
+	chunks, _ := recordConnection(t, tls_client.Chrome_107, tls.VersionTLS13)
+
+	// the client part of the capture ends within the ClientHello
+	truncated := []capturedChunk{{fromClient: true, data: chunks[0].data[:100]}, chunks[1]}
+
+	profiles, err := tls_client.ProfilesFromCapture(writeCapture(truncated, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, profiles, 1) {
+		return
+	}
+
+	assert.Equal(t, "10.0.0.1:50000", profiles[0].Client)
+	assert.Error(t, profiles[0].Err)
+	assert.Empty(t, profiles[0].ServerName)
+}
+
+func TestProfilesFromCapture_CutOffCapture(t *testing.T) {
+	chunks, _ := recordConnection(t, tls_client.Chrome_107, tls.VersionTLS13)
+
+	for _, pcapng := range []bool{false, true} {
+		capture := writeCapture(chunks, pcapng)
+
+		profiles, err := tls_client.ProfilesFromCapture(capture[:len(capture)-10], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !assert.Len(t, profiles, 1) {
+			continue
+		}
+
+		assert.NoError(t, profiles[0].Err)
+		assert.Equal(t, "example.com", profiles[0].ServerName)
+	}
+}
+
+func TestProfilesFromCapture_RejectsOtherData(t *testing.T) {
+	_, err := tls_client.ProfilesFromCapture([]byte("not a capture"), nil)
+	assert.Error(t, err)
+}
+
+// capturedChunk is one read of a recorded connection.
+type capturedChunk struct {
+	fromClient bool
+	data       []byte
+}
+
+// recordConnection sends a request with the profile through a recording proxy to a local http2 server
+// and returns what went over the wire together with the key log of the server.
+func recordConnection(t *testing.T, profile tls_client.ClientProfile, maxVersion uint16) ([]capturedChunk, []byte) {
+	var mu sync.Mutex
+	var keyLog bytes.Buffer
+	var chunks []capturedChunk
+
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	testServer.TLS = &tls.Config{MaxVersion: maxVersion, KeyLogWriter: lockedWriter{mu: &mu, w: &keyLog}}
+	testServer.EnableHTTP2 = true
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		client, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer client.Close()
+
+		server, err := net.Dial("tcp", testServer.Listener.Addr().String())
+		if err != nil {
+			return
+		}
+		defer server.Close()
+
+		forward := func(dst net.Conn, src net.Conn, fromClient bool) {
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := src.Read(buf)
+				if n > 0 {
+					mu.Lock()
+					chunks = append(chunks, capturedChunk{fromClient: fromClient, data: append([]byte(nil), buf[:n]...)})
+					mu.Unlock()
+
+					_, _ = dst.Write(buf[:n])
+				}
+
+				if err != nil {
+					_ = dst.Close()
+					return
+				}
+			}
+		}
+
+		go forward(server, client, true)
+		forward(client, server, false)
+	}()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(profile), tls_client.WithInsecureSkipVerify(), tls_client.WithServerNameOverwrite("example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get("https://" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]capturedChunk(nil), chunks...), append([]byte(nil), keyLog.Bytes()...)
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
+
+// writeCapture writes the chunks as ethernet frames of a tcp connection from 10.0.0.1:50000 to 10.0.0.2:443.
+// The chunks are split into small segments, the first data segment is retransmitted and two segments are swapped.
+func writeCapture(chunks []capturedChunk, pcapng bool) []byte {
+	clientSeq, serverSeq := uint32(1000), uint32(0xfffffff0)
+
+	frames := [][]byte{
+		tcpFrame(true, clientSeq, 0x02, nil),
+		tcpFrame(false, serverSeq, 0x12, nil),
+	}
+	clientSeq++
+	serverSeq++
+
+	for _, chunk := range chunks {
+		for data := chunk.data; len(data) > 0; {
+			segment := data
+			if len(segment) > 300 {
+				segment = segment[:300]
+			}
+			data = data[len(segment):]
+
+			if chunk.fromClient {
+				frames = append(frames, tcpFrame(true, clientSeq, 0x18, segment))
+				clientSeq += uint32(len(segment))
+			} else {
+				frames = append(frames, tcpFrame(false, serverSeq, 0x18, segment))
+				serverSeq += uint32(len(segment))
+			}
+		}
+	}
+
+	frames = append([][]byte{frames[0], frames[1], frames[2]}, frames[2:]...)
+	frames[4], frames[5] = frames[5], frames[4]
+
+	var out bytes.Buffer
+	le := binary.LittleEndian
+
+	if !pcapng {
+		_ = binary.Write(&out, le, []uint32{0xa1b2c3d4, 2 | 4<<16, 0, 0, 65535, 1})
+		for _, frame := range frames {
+			_ = binary.Write(&out, le, []uint32{0, 0, uint32(len(frame)), uint32(len(frame))})
+			out.Write(frame)
+		}
+
+		return out.Bytes()
+	}
+
+	_ = binary.Write(&out, le, []uint32{0x0a0d0d0a, 28, 0x1a2b3c4d, 1, 0xffffffff, 0xffffffff, 28})
+	_ = binary.Write(&out, le, []uint32{1, 20, 1, 65535, 20})
+	for _, frame := range frames {
+		padded := append(frame, make([]byte, (4-len(frame)%4)%4)...)
+		length := uint32(32 + len(padded))
+
+		_ = binary.Write(&out, le, []uint32{6, length, 0, 0, 0, uint32(len(frame)), uint32(len(frame))})
+		out.Write(padded)
+		_ = binary.Write(&out, le, length)
+	}
+
+	return out.Bytes()
+}
+
+func tcpFrame(fromClient bool, seq uint32, flags byte, payload []byte) []byte {
+	client, server := []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}
+	srcPort, dstPort := uint16(50000), uint16(443)
+	src, dst := client, server
+	if !fromClient {
+		src, dst, srcPort, dstPort = server, client, dstPort, srcPort
+	}
+
+	frame := []byte{2, 0, 0, 0, 0, 2, 2, 0, 0, 0, 0, 1, 0x08, 0x00}
+
+	ip := make([]byte, 20)
+	ip[0], ip[8], ip[9] = 0x45, 64, 6
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+20+len(payload)))
+	copy(ip[12:], src)
+	copy(ip[16:], dst)
+
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	tcp[12], tcp[13] = 5<<4, flags
+	binary.BigEndian.PutUint16(tcp[14:], 65535)
+
+	return append(append(append(frame, ip...), tcp...), payload...)
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	tls "github.com/bogdanfinn/utls"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileFromClientHello_ReplicatesProfiles(t *testing.T) {
+	for _, name := range []string{"chrome_107", "firefox_106", "safari_16_0", "opera_91", "zalando_android_mobile", "nike_ios_mobile"} {
+		profile, err := tls_client.LookupProfile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		imported, err := tls_client.ProfileFromClientHello(captureClientHello(t, profile.GetClientHelloId()))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// both alps extensions of utls are written the same way, the imported profiles use the one whose protocols follow WithALPN
+		expected := strings.ReplaceAll(marshalClientHello(t, profile), "name: alps", "name: application_settings")
+
+		assert.Equal(t, expected, marshalClientHello(t, imported), name)
+	}
+}
+
+func TestProfileFromClientHello_HandshakeMessageAndHexDump(t *testing.T) {
+	record := captureClientHello(t, tls.HelloChrome_107)
+
+	fromRecord, err := tls_client.ProfileFromClientHello(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without the 5 byte record header
+	fromMessage, err := tls_client.ProfileFromClientHello(record[5:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dump strings.Builder
+	for i, b := range record {
+		if i > 0 && i%16 == 0 {
+			dump.WriteString("\n")
+		}
+
+		dump.WriteString(hex.EncodeToString([]byte{b}) + " ")
+	}
+
+	fromDump, err := tls_client.ProfileFromClientHello([]byte(dump.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, marshalClientHello(t, fromRecord), marshalClientHello(t, fromMessage))
+	assert.Equal(t, marshalClientHello(t, fromRecord), marshalClientHello(t, fromDump))
+}
+
+func TestProfileFromClientHello_KeepsUnknownExtensions(t *testing.T) {
+	spec := tls.ClientHelloSpec{
+		CipherSuites:       []uint16{tls.TLS_AES_128_GCM_SHA256},
+		CompressionMethods: []uint8{0},
+		Extensions: []tls.TLSExtension{
+			&tls.SNIExtension{},
+			&tls.GenericExtension{Id: 0x4242, Data: []byte{1, 2, 3}},
+			&tls.FakeRecordSizeLimitExtension{Limit: 0x4001},
+			// a malformed application settings extension
+			&tls.GenericExtension{Id: 17513, Data: []byte{0, 5, 2}},
+			&tls.SupportedVersionsExtension{Versions: []uint16{tls.VersionTLS13, tls.VersionTLS12}},
+			&tls.KeyShareExtension{KeyShares: []tls.KeyShare{{Group: tls.X25519}}},
+		},
+	}
+
+	id := tls.ClientHelloID{
+		Client:  "Test",
+		Version: "1",
+		SpecFactory: func() (tls.ClientHelloSpec, error) {
+			return spec, nil
+		},
+	}
+
+	profile, err := tls_client.ProfileFromClientHello(captureClientHello(t, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := profile.GetClientHelloSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, imported.Extensions, 6)
+	assert.Equal(t, &tls.GenericExtension{Id: 0x4242, Data: []byte{1, 2, 3}}, imported.Extensions[1])
+	assert.Equal(t, &tls.FakeRecordSizeLimitExtension{Limit: 0x4001}, imported.Extensions[2])
+	assert.Equal(t, &tls.GenericExtension{Id: 17513, Data: []byte{0, 5, 2}}, imported.Extensions[3])
+}
+
+func TestProfileFromClientHello_RejectsOtherData(t *testing.T) {
+	_, err := tls_client.ProfileFromClientHello([]byte("GET / HTTP/1.1\r\n\r\n"))
+	assert.Error(t, err)
+
+	_, err = tls_client.ProfileFromClientHello([]byte{22, 3, 1, 0, 10, 1, 0, 0})
+	assert.Error(t, err)
+}
+
+// captureClientHello returns the first tls record a client with the id sends.
+func captureClientHello(t *testing.T, id tls.ClientHelloID) []byte {
+	return captureClientHelloRecord(t, id, false)
+}
+
+func captureClientHelloRecord(t *testing.T, id tls.ClientHelloID, randomExtensionOrder bool) []byte {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		defer client.Close()
+
+		conn := tls.UClient(client, &tls.Config{ServerName: "example.com", InsecureSkipVerify: true}, id, randomExtensionOrder)
+		_ = conn.Handshake()
+	}()
+
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(server, header); err != nil {
+		t.Fatal(err)
+	}
+
+	record := make([]byte, 5+(int(header[3])<<8|int(header[4])))
+	copy(record, header)
+
+	if _, err := io.ReadFull(server, record[5:]); err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+// marshalClientHello returns the client hello of the profile in the profile format without its name.
+func marshalClientHello(t *testing.T, profile tls_client.ClientProfile) string {
+	data, err := tls_client.MarshalProfile(profile, tls_client.ProfileFormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	var clientHello []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "http2:") {
+			break
+		}
+
+		if !strings.HasPrefix(line, "  client:") && !strings.HasPrefix(line, "  version:") {
+			clientHello = append(clientHello, line)
+		}
+	}
+
+	return strings.Join(clientHello, "\n")
+}
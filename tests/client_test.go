@@ -50,6 +50,7 @@ var defaultHeader = http.Header{
 func chrome107(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Chrome_107),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(nil, options...)
@@ -57,7 +58,7 @@ func chrome107(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -75,6 +76,7 @@ func chrome107(t *testing.T) {
 func chrome105(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Chrome_105),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(nil, options...)
@@ -82,7 +84,7 @@ func chrome105(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -100,6 +102,7 @@ func chrome105(t *testing.T) {
 func safari_16_0(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Safari_16_0),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(nil, options...)
@@ -107,7 +110,7 @@ func safari_16_0(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -125,6 +128,7 @@ func safari_16_0(t *testing.T) {
 func safari_iOS_16_0(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Safari_IOS_16_0),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(nil, options...)
@@ -132,7 +136,7 @@ func safari_iOS_16_0(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -150,6 +154,7 @@ func safari_iOS_16_0(t *testing.T) {
 func firefox_105(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Firefox_105),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(nil, options...)
@@ -157,7 +162,7 @@ func firefox_105(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -175,6 +180,7 @@ func firefox_105(t *testing.T) {
 func firefox_106(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Firefox_106),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(nil, options...)
@@ -182,7 +188,7 @@ func firefox_106(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -200,6 +206,7 @@ func firefox_106(t *testing.T) {
 func opera_91(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Opera_91),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(nil, options...)
@@ -207,7 +214,7 @@ func opera_91(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1,6 +1,13 @@
 package tests
 
-import tls "github.com/bogdanfinn/utls"
+import (
+	"reflect"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	tls "github.com/bogdanfinn/utls"
+	"github.com/stretchr/testify/assert"
+)
 
 const (
 	chrome       = "chrome"
@@ -10,15 +17,16 @@ const (
 	safariIpadOs = "safari_Ipad"
 	safariIos    = "safari_IOS"
 
-	peetApiEndpoint = "https://tls.peet.ws/api/all"
-	//peetApiEndpoint = "https://tls.cloudscraper.io/api/all"
-
 	ja3String             = "ja3String"
 	ja3Hash               = "ja3Hash"
 	akamaiFingerprint     = "akamaiFingerprint"
 	akamaiFingerprintHash = "akamaiFingerprintHash"
 )
 
+// fingerprintApiEndpoint is the url of the local fingerprint server started by TestMain,
+// it answers like https://tls.peet.ws/api/all.
+var fingerprintApiEndpoint string
+
 var browserFingerprints = map[string]map[string]map[string]string{
 	chrome: {
 		tls.HelloChrome_107.Str(): map[string]string{
@@ -141,3 +149,93 @@ var browserFingerprints = map[string]map[string]map[string]string{
 		},
 	},
 }
+
+// assertSameProfile compares everything a profile sends, the client hello spec field by field and the http2 fingerprint.
+// The names of the client hello ids are not sent and not compared.
+func assertSameProfile(t *testing.T, name string, expected tls_client.ClientProfile, actual tls_client.ClientProfile) {
+	t.Helper()
+
+	assert.Equal(t, expected.GetClientHelloId().RandomExtensionOrder, actual.GetClientHelloId().RandomExtensionOrder, name)
+
+	expectedSpec, err := clientHelloSpecOf(expected)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	actualSpec, err := clientHelloSpecOf(actual)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	assert.Equal(t, expectedSpec.TLSVersMin, actualSpec.TLSVersMin, name)
+	assert.Equal(t, expectedSpec.TLSVersMax, actualSpec.TLSVersMax, name)
+	assert.Equal(t, expectedSpec.CipherSuites, actualSpec.CipherSuites, name)
+	assert.Equal(t, expectedSpec.CompressionMethods, actualSpec.CompressionMethods, name)
+
+	if assert.Len(t, actualSpec.Extensions, len(expectedSpec.Extensions), name) {
+		for i := range expectedSpec.Extensions {
+			expectedExtension, actualExtension := expectedSpec.Extensions[i], actualSpec.Extensions[i]
+
+			// funcs are only equal if both are nil, the padding funcs are compared by their address
+			if expectedPadding, ok := expectedExtension.(*tls.UtlsPaddingExtension); ok {
+				actualPadding, ok := actualExtension.(*tls.UtlsPaddingExtension)
+				if !assert.True(t, ok, "%s: extension %d", name, i) {
+					continue
+				}
+
+				assert.Equal(t, funcPointer(expectedPadding.GetPaddingLen), funcPointer(actualPadding.GetPaddingLen), "%s: extension %d", name, i)
+
+				expectedCopy, actualCopy := *expectedPadding, *actualPadding
+				expectedCopy.GetPaddingLen, actualCopy.GetPaddingLen = nil, nil
+				expectedExtension, actualExtension = &expectedCopy, &actualCopy
+			}
+
+			assert.Equal(t, sentExtension(expectedExtension), sentExtension(actualExtension), "%s: extension %d", name, i)
+		}
+	}
+
+	assert.Equal(t, expected.GetSettings(), actual.GetSettings(), name)
+	assert.Equal(t, expected.GetSettingsOrder(), actual.GetSettingsOrder(), name)
+	assert.Equal(t, expected.GetConnectionFlow(), actual.GetConnectionFlow(), name)
+	assert.Equal(t, expected.GetPriorities(), actual.GetPriorities(), name)
+	assert.Equal(t, expected.GetPseudoHeaderOrder(), actual.GetPseudoHeaderOrder(), name)
+
+	expectedJa3, err := expected.JA3()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	actualJa3, err := actual.JA3()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	assert.Equal(t, expectedJa3, actualJa3, name)
+	assert.Equal(t, expected.AkamaiFingerprint(), actual.AkamaiFingerprint(), name)
+}
+
+// clientHelloSpecOf returns the spec utls sends for the profile, utls uses its own spec for the ids it knows.
+func clientHelloSpecOf(profile tls_client.ClientProfile) (tls.ClientHelloSpec, error) {
+	if spec, err := tls.UTLSIdToSpec(profile.GetClientHelloId()); err == nil {
+		return spec, nil
+	}
+
+	return profile.GetClientHelloSpec()
+}
+
+// sentExtension returns the extension as the type which parsed client hellos use, ALPS and application settings send the same extension.
+func sentExtension(extension tls.TLSExtension) tls.TLSExtension {
+	if alps, ok := extension.(*tls.ALPSExtension); ok {
+		return &tls.ApplicationSettingsExtension{SupportedProtocols: alps.SupportedProtocols}
+	}
+
+	return extension
+}
+
+func funcPointer(f interface{}) uintptr {
+	if reflect.ValueOf(f).IsNil() {
+		return 0
+	}
+
+	return reflect.ValueOf(f).Pointer()
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/bogdanfinn/fhttp/httptest"
+	tls "github.com/bogdanfinn/utls"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ConnectionInfoHttp2(t *testing.T) {
+	testServer := newTLSTestServer()
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(testServer.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		info := tls_client.GetConnectionInfo(resp)
+		if !assert.NotNil(t, info) {
+			return
+		}
+
+		assert.Equal(t, "HTTP/2.0", info.Protocol)
+		assert.Equal(t, uint16(tls.VersionTLS13), info.TLSVersion)
+		assert.NotZero(t, info.CipherSuite)
+		assert.Equal(t, "h2", info.NegotiatedProtocol)
+		if assert.Len(t, info.PeerCertificates, 1) {
+			assert.Equal(t, testServer.Certificate().Raw, info.PeerCertificates[0].Raw)
+		}
+		assert.Contains(t, info.ServerH2Settings, http2.Setting{ID: http2.SettingMaxConcurrentStreams, Val: 250})
+		assert.Equal(t, testServer.Listener.Addr().String(), info.RemoteAddr.String())
+		assert.NotNil(t, info.LocalAddr)
+		assert.Empty(t, info.Proxy)
+		// the first request uses the connection of the negotiation, the second one reuses it
+		assert.Equal(t, i == 1, info.Reused)
+	}
+}
+
+func TestClient_ConnectionInfoHttp1(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify(), tls_client.WithForceHttp1())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(testServer.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		info := tls_client.GetConnectionInfo(resp)
+		if !assert.NotNil(t, info) {
+			return
+		}
+
+		assert.Equal(t, "HTTP/1.1", info.Protocol)
+		assert.Equal(t, "http/1.1", info.NegotiatedProtocol)
+		assert.NotZero(t, info.TLSVersion)
+		assert.Nil(t, info.ServerH2Settings)
+		assert.Equal(t, testServer.Listener.Addr().String(), info.RemoteAddr.String())
+		assert.Equal(t, i == 1, info.Reused)
+	}
+}
+
+func TestClient_ConnectionInfoProxy(t *testing.T) {
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	proxyServer := newConnectProxy(t)
+	proxyServer.requireAuth("Basic", "user", "secret")
+	defer proxyServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithProxyUrl(fmt.Sprintf("http://user:secret@%s", proxyServer.Addr())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	info := tls_client.GetConnectionInfo(resp)
+	if !assert.NotNil(t, info) {
+		return
+	}
+
+	assert.Equal(t, "HTTP/1.1", info.Protocol)
+	assert.Zero(t, info.TLSVersion)
+	assert.Empty(t, info.PeerCertificates)
+	assert.Equal(t, fmt.Sprintf("http://user:xxxxx@%s", proxyServer.Addr()), info.Proxy)
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"bufio"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_MixedHttpAndHttpsOnSameHostAndPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newMixedSchemeServer(listener)
+	defer server.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, scheme := range []string{"https", "http", "https", "http"} {
+		resp, err := client.Get(scheme + "://" + listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		assert.Equal(t, scheme, resp.Header.Get("X-Scheme"))
+
+		if scheme == "https" {
+			assert.Equal(t, 2, resp.ProtoMajor)
+		} else {
+			assert.Equal(t, 1, resp.ProtoMajor)
+		}
+	}
+}
+
+func TestClient_IPv6Literal(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("ipv6 is not available: %v", err)
+	}
+
+	server := newMixedSchemeServer(listener)
+	defer server.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	for _, scheme := range []string{"https", "http"} {
+		u := &url.URL{Scheme: scheme, Host: "[::1]:" + port, Path: "/"}
+
+		resp, err := client.Get(u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, scheme, resp.Header.Get("X-Scheme"))
+	}
+}
+
+// mixedSchemeServer serves http and https on one listener by looking at the first byte of every connection.
+type mixedSchemeServer struct {
+	listener    net.Listener
+	httpServer  *httptest.Server
+	httpsServer *httptest.Server
+}
+
+func newMixedSchemeServer(listener net.Listener) *mixedSchemeServer {
+	handler := func(scheme string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Scheme", scheme)
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+
+	httpListener := newChanListener(listener.Addr())
+	httpsListener := newChanListener(listener.Addr())
+
+	s := &mixedSchemeServer{
+		listener:    listener,
+		httpServer:  &httptest.Server{Listener: httpListener, Config: &http.Server{Handler: handler("http")}},
+		httpsServer: &httptest.Server{Listener: httpsListener, Config: &http.Server{Handler: handler("https")}, EnableHTTP2: true},
+	}
+
+	s.httpServer.Start()
+	s.httpsServer.StartTLS()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				reader := bufio.NewReader(conn)
+				first, err := reader.Peek(1)
+				if err != nil {
+					_ = conn.Close()
+					return
+				}
+
+				peeked := &peekedConn{Conn: conn, reader: reader}
+
+				// 0x16 is the record type of a tls handshake
+				if first[0] == 0x16 {
+					httpsListener.conns <- peeked
+				} else {
+					httpListener.conns <- peeked
+				}
+			}()
+		}
+	}()
+
+	return s
+}
+
+func (s *mixedSchemeServer) Close() {
+	_ = s.listener.Close()
+	s.httpServer.Close()
+	s.httpsServer.Close()
+}
+
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// chanListener hands out the connections sent to its channel.
+type chanListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newChanListener(addr net.Addr) *chanListener {
+	return &chanListener{addr: addr, conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *chanListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *chanListener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+	})
+
+	return nil
+}
+
+func (l *chanListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ContextCancelsHangingHandshake(t *testing.T) {
+	listener := newSilentListener(t)
+	defer listener.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.GetContext(ctx, "https://"+listener.Addr().String())
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestClient_ContextCancelsHangingProxyConnect(t *testing.T) {
+	proxyListener := newSilentListener(t)
+	defer proxyListener.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithProxyUrl("http://"+proxyListener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err = client.GetContext(ctx, "https://example.com")
+
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestClient_CanceledNegotiationDoesNotFailWaitingRequests(t *testing.T) {
+	testServer := newTLSTestServer()
+	defer testServer.Close()
+
+	forwarder := newSlowForwarder(t, testServer.Listener.Addr().String(), 300*time.Millisecond)
+	defer forwarder.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shortCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		_, err := client.GetContext(shortCtx, "https://"+forwarder.Addr())
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		// give the short request the lead so that this one waits for its negotiation
+		time.Sleep(20 * time.Millisecond)
+
+		resp, err := client.GetContext(context.Background(), "https://"+forwarder.Addr())
+		if assert.NoError(t, err) {
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	}()
+
+	wg.Wait()
+}
+
+func TestClient_PostContext(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		w.Header().Set("X-Content-Type", req.Header.Get("Content-Type"))
+		w.Header().Set("X-Method", req.Method)
+		_, _ = w.Write(body)
+	}))
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.PostContext(context.Background(), testServer.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, "text/plain", resp.Header.Get("X-Content-Type"))
+	assert.Equal(t, http.MethodPost, resp.Header.Get("X-Method"))
+
+	resp, err = client.HeadContext(context.Background(), testServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.MethodHead, resp.Header.Get("X-Method"))
+}
+
+// newSilentListener accepts connections but never writes to them.
+func newSilentListener(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			conns = append(conns, conn)
+		}
+	}()
+
+	return listener
+}
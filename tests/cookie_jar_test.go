@@ -8,6 +8,7 @@ import (
 	tls_client "github.com/Digman/tls-client"
 	http "github.com/bogdanfinn/fhttp"
 	"github.com/bogdanfinn/fhttp/cookiejar"
+	"github.com/bogdanfinn/fhttp/httptest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,11 +63,15 @@ func TestClient_SkipExistingCookiesOnClientSetCookies(t *testing.T) {
 }
 
 func TestClient_SkipExistingCookiesOnSetCookiesResponse(t *testing.T) {
+	testServer := newCookieTestServer()
+	defer testServer.Close()
+
 	jar, _ := cookiejar.New(nil)
 
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Chrome_105),
 		tls_client.WithCookieJar(jar),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
@@ -78,7 +83,7 @@ func TestClient_SkipExistingCookiesOnSetCookiesResponse(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, "https://de.topps.com/", nil)
+	req, err := http.NewRequest(http.MethodGet, testServer.URL, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -108,10 +113,9 @@ func TestClient_SkipExistingCookiesOnSetCookiesResponse(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	u := &url.URL{
-		Scheme: "https",
-		Host:   "de.topps.com",
-		Path:   "",
+	u, err := url.Parse(testServer.URL)
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	cookiesAfterFirstRequest := client.GetCookies(u)
@@ -127,7 +131,7 @@ func TestClient_SkipExistingCookiesOnSetCookiesResponse(t *testing.T) {
 
 	assert.Equal(t, 1, len(client.GetCookies(u)))
 
-	req, err = http.NewRequest(http.MethodGet, "https://de.topps.com/", nil)
+	req, err = http.NewRequest(http.MethodGet, testServer.URL, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -163,11 +167,15 @@ func TestClient_SkipExistingCookiesOnSetCookiesResponse(t *testing.T) {
 }
 
 func TestClient_SkipExistingCookiesOnRequest(t *testing.T) {
+	testServer := newCookieTestServer()
+	defer testServer.Close()
+
 	jar, _ := cookiejar.New(nil)
 
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Chrome_105),
 		tls_client.WithCookieJar(jar),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
@@ -179,7 +187,7 @@ func TestClient_SkipExistingCookiesOnRequest(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, "https://de.topps.com/", nil)
+	req, err := http.NewRequest(http.MethodGet, testServer.URL, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -209,10 +217,9 @@ func TestClient_SkipExistingCookiesOnRequest(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	u := &url.URL{
-		Scheme: "https",
-		Host:   "de.topps.com",
-		Path:   "",
+	u, err := url.Parse(testServer.URL)
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	cookiesAfterFirstRequest := client.GetCookies(u)
@@ -225,7 +232,7 @@ func TestClient_SkipExistingCookiesOnRequest(t *testing.T) {
 		Domain: cookiesAfterFirstRequest[0].Domain,
 	}
 
-	req, err = http.NewRequest(http.MethodGet, "https://de.topps.com/", nil)
+	req, err = http.NewRequest(http.MethodGet, testServer.URL, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -262,3 +269,15 @@ func TestClient_SkipExistingCookiesOnRequest(t *testing.T) {
 	assert.Equal(t, 1, len(cookiesAfterSecondRequest))
 
 }
+
+// newCookieTestServer starts a server which sets the same session cookie on every response.
+func newCookieTestServer() *httptest.Server {
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "c29tZS1zZXNzaW9u", Path: "/", HttpOnly: true})
+		w.WriteHeader(http.StatusOK)
+	}))
+	testServer.EnableHTTP2 = true
+	testServer.StartTLS()
+
+	return testServer
+}
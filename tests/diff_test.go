@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffProfiles_SameProfile(t *testing.T) {
+	diff, err := tls_client.DiffProfiles(tls_client.Chrome_107, tls_client.Chrome_107)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, diff.Empty())
+	assert.Equal(t, tls_client.ProfileDiff{}, diff)
+	assert.Equal(t, "profiles do not differ\n", diff.String())
+}
+
+func TestDiffProfiles_Chrome106Chrome107(t *testing.T) {
+	diff, err := tls_client.DiffProfiles(tls_client.Chrome_106, tls_client.Chrome_107)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// chrome 107 sends the same extensions in another order
+	assert.True(t, diff.Extensions.Reordered)
+	assert.Empty(t, diff.Extensions.Added)
+	assert.Empty(t, diff.Extensions.Removed)
+	assert.Equal(t, "status_request", diff.Extensions.B[1])
+
+	assert.True(t, diff.CipherSuites.Empty())
+	assert.Empty(t, diff.ExtensionParameters)
+	assert.True(t, diff.KeyShares.Empty())
+	assert.Empty(t, diff.Settings)
+	assert.True(t, diff.ConnectionFlow.Empty())
+	assert.True(t, diff.PseudoHeaderOrder.Empty())
+
+	assert.Contains(t, diff.String(), "extensions:\n  order: grease, server_name, extended_master_secret,")
+}
+
+func TestDiffProfiles_ChromeFirefox(t *testing.T) {
+	diff, err := tls_client.DiffProfiles(tls_client.Chrome_107, tls_client.Firefox_106)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA", "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA"}, diff.CipherSuites.Added)
+	assert.Equal(t, []string{"GREASE"}, diff.CipherSuites.Removed)
+
+	assert.Equal(t, []string{"delegated_credentials", "record_size_limit"}, diff.Extensions.Added)
+	assert.Equal(t, []string{"grease", "signed_certificate_timestamp", "compress_certificate", "alps", "grease"}, diff.Extensions.Removed)
+
+	assert.Contains(t, diff.ExtensionParameters, tls_client.ExtensionDiff{
+		Name:       "supported_versions",
+		Parameters: []tls_client.ValueDiff{{Name: "versions", A: `["GREASE","1.3","1.2"]`, B: `["1.3","1.2"]`}},
+	})
+
+	assert.Equal(t, []string{"P256"}, diff.KeyShares.Added)
+	assert.Equal(t, []string{"GREASE:00"}, diff.KeyShares.Removed)
+
+	assert.Contains(t, diff.Settings, tls_client.ValueDiff{Name: "INITIAL_WINDOW_SIZE", A: "6291456", B: "131072"})
+	assert.Contains(t, diff.Settings, tls_client.ValueDiff{Name: "MAX_FRAME_SIZE", A: "", B: "16384"})
+	assert.Equal(t, tls_client.ValueDiff{Name: "connectionFlow", A: "15663105", B: "12517377"}, diff.ConnectionFlow)
+	assert.Len(t, diff.Priorities.Added, 6)
+	assert.True(t, diff.PseudoHeaderOrder.Reordered)
+}
+
+func TestDiffProfiles_Http2(t *testing.T) {
+	a := tls_client.ProfileFrom(tls_client.Chrome_107).WithSettings(nil, nil).Build()
+	b := tls_client.ProfileFrom(tls_client.Chrome_107).
+		WithSettings(map[http2.SettingID]uint32{
+			http2.SettingMaxConcurrentStreams: 1000,
+			http2.SettingHeaderTableSize:      65536,
+			http2.SettingInitialWindowSize:    131072,
+			http2.SettingMaxFrameSize:         16384,
+		}, []http2.SettingID{http2.SettingMaxConcurrentStreams, http2.SettingHeaderTableSize, http2.SettingInitialWindowSize, http2.SettingMaxFrameSize}).
+		WithPriorities([]http2.Priority{{StreamID: 3, PriorityParam: http2.PriorityParam{Weight: 200}}}).
+		WithPseudoHeaderOrder([]string{":method", ":authority", ":path", ":scheme"}).
+		Build()
+
+	diff, err := tls_client.DiffProfiles(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a sends DefaultH2Settings
+	assert.Equal(t, []tls_client.ValueDiff{{Name: "INITIAL_WINDOW_SIZE", A: "6291456", B: "131072"}}, diff.Settings)
+	assert.True(t, diff.SettingsOrder.Reordered)
+	assert.Empty(t, diff.SettingsOrder.Added)
+	assert.Equal(t, []string{"3:0:0:201"}, diff.Priorities.Added)
+	assert.True(t, diff.ConnectionFlow.Empty())
+
+	assert.True(t, diff.CipherSuites.Empty())
+	assert.True(t, diff.Extensions.Empty())
+
+	assert.Equal(t, "http2 settings:\n"+
+		"  INITIAL_WINDOW_SIZE: 6291456 -> 131072\n"+
+		"http2 settings order:\n"+
+		"  order: HEADER_TABLE_SIZE, MAX_CONCURRENT_STREAMS, INITIAL_WINDOW_SIZE, MAX_FRAME_SIZE -> MAX_CONCURRENT_STREAMS, HEADER_TABLE_SIZE, INITIAL_WINDOW_SIZE, MAX_FRAME_SIZE\n"+
+		"priorities:\n"+
+		"  + 3:0:0:201\n"+
+		"pseudo header order:\n"+
+		"  order: :method, :authority, :scheme, :path -> :method, :authority, :path, :scheme\n", diff.String())
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	tls "github.com/bogdanfinn/utls"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ErrorProxyAuthRequired(t *testing.T) {
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	proxyServer := newConnectProxy(t)
+	proxyServer.requireAuth("Basic", "user", "secret")
+	defer proxyServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105), tls_client.WithProxyUrl("http://"+proxyServer.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get(testServer.URL + "/index")
+
+	assert.True(t, errors.Is(err, tls_client.ErrProxyAuthRequired))
+
+	var connectErr *tls_client.ProxyConnectError
+	if assert.True(t, errors.As(err, &connectErr)) {
+		assert.Equal(t, http.StatusProxyAuthRequired, connectErr.StatusCode)
+		assert.Contains(t, connectErr.Header.Get("Proxy-Authenticate"), "Basic")
+	}
+}
+
+func TestClient_ErrorProxyConnectBadGateway(t *testing.T) {
+	closedAddr := closedPortAddr(t)
+
+	proxyServer := newConnectProxy(t)
+	defer proxyServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105), tls_client.WithProxyUrl("http://"+proxyServer.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get("https://" + closedAddr)
+
+	assert.False(t, errors.Is(err, tls_client.ErrProxyAuthRequired))
+
+	var connectErr *tls_client.ProxyConnectError
+	if assert.True(t, errors.As(err, &connectErr)) {
+		assert.Equal(t, http.StatusBadGateway, connectErr.StatusCode)
+		assert.Contains(t, err.Error(), "Proxy responded with non 200 code: 502 Bad Gateway")
+	}
+
+	var urlErr *url.Error
+	assert.True(t, errors.As(err, &urlErr))
+}
+
+func TestClient_ErrorDial(t *testing.T) {
+	closedAddr := closedPortAddr(t)
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get("https://" + closedAddr)
+
+	var dialErr *tls_client.DialError
+	if assert.True(t, errors.As(err, &dialErr)) {
+		assert.Equal(t, closedAddr, dialErr.Addr)
+		assert.Empty(t, dialErr.Proxy)
+	}
+}
+
+func TestClient_ErrorDialProxyRedactsPassword(t *testing.T) {
+	closedAddr := closedPortAddr(t)
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105), tls_client.WithProxyUrl(fmt.Sprintf("http://user:secret@%s", closedAddr)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get("https://example.com")
+
+	var dialErr *tls_client.DialError
+	if assert.True(t, errors.As(err, &dialErr)) {
+		assert.Equal(t, closedAddr, dialErr.Addr)
+		assert.NotEmpty(t, dialErr.Proxy)
+		assert.NotContains(t, err.Error(), "secret")
+	}
+}
+
+func TestClient_ErrorTLSHandshakeAlert(t *testing.T) {
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	// a cipher suite which is not offered by the profile lets the server abort with a handshake_failure alert
+	testServer.TLS = &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256},
+	}
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105), tls_client.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get(testServer.URL)
+
+	var handshakeErr *tls_client.TLSHandshakeError
+	if assert.True(t, errors.As(err, &handshakeErr)) {
+		assert.Equal(t, "127.0.0.1", handshakeErr.ServerName)
+		assert.True(t, handshakeErr.HasAlert)
+		assert.Equal(t, uint8(40), handshakeErr.Alert)
+	}
+}
+
+func TestClient_ErrorTLSHandshakeWithoutAlert(t *testing.T) {
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testServerUrl, _ := url.Parse(testServer.URL)
+	_, err = client.Get("https://" + testServerUrl.Host)
+
+	var handshakeErr *tls_client.TLSHandshakeError
+	if assert.True(t, errors.As(err, &handshakeErr)) {
+		assert.False(t, handshakeErr.HasAlert)
+	}
+}
+
+func closedPortAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	return addr
+}
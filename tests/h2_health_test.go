@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tls_client "github.com/Digman/tls-client"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestClient_H2HealthCheckReplacesDeadConnection(t *testing.T) {
+	onRequest := func(conn int, streamId uint32, body []byte, fr *http2.Framer) {
+		writeRawH2Response(fr, streamId)
+	}
+	// the first connection stops answering pings, like one behind a proxy which lost the server
+	ignorePings := func(conn int) bool {
+		return conn == 0
+	}
+
+	server := newRawH2CServer(t, onRequest, ignorePings)
+	defer server.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithH2C(tls_client.H2CPriorKnowledge), tls_client.WithH2HealthCheck(tls_client.H2HealthCheck{
+		ReadIdleTimeout: 100 * time.Millisecond,
+		PingTimeout:     100 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		assert.Equal(t, 200, resp.StatusCode)
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	assert.Equal(t, 2, server.Connections())
+}
+
+func TestClient_H2GoAwayRetriesUnprocessedRequests(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+
+	server := newRawH2CServer(t, func(conn int, streamId uint32, body []byte, fr *http2.Framer) {
+		// the first connection processes its first stream only and shuts down gracefully on the next one
+		if conn == 0 && streamId > 1 {
+			_ = fr.WriteGoAway(1, http2.ErrCodeNo, nil)
+			return
+		}
+
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+
+		writeRawH2Response(fr, streamId)
+	}, nil)
+	defer server.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithH2C(tls_client.H2CPriorKnowledge))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{"first", "second", "third"} {
+		resp, err := client.Post(server.URL(), "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the second request was not processed by the first connection and sent again on a new one, which is used afterwards
+	assert.Equal(t, []string{"first", "second", "third"}, bodies)
+	assert.Equal(t, 2, server.Connections())
+}
+
+// rawH2CServer speaks h2c with prior knowledge frame by frame, so that tests control every frame of the server.
+type rawH2CServer struct {
+	listener net.Listener
+	// onRequest runs on the reading goroutine of the connection for every complete request
+	onRequest func(conn int, streamId uint32, body []byte, fr *http2.Framer)
+	// ignorePings keeps the connection with the index from answering PING frames, nil answers all of them
+	ignorePings func(conn int) bool
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newRawH2CServer(t *testing.T, onRequest func(conn int, streamId uint32, body []byte, fr *http2.Framer), ignorePings func(conn int) bool) *rawH2CServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &rawH2CServer{
+		listener:    listener,
+		onRequest:   onRequest,
+		ignorePings: ignorePings,
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.mu.Lock()
+			index := len(server.conns)
+			server.conns = append(server.conns, conn)
+			server.mu.Unlock()
+
+			go server.serve(index, conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *rawH2CServer) serve(index int, conn net.Conn) {
+	defer conn.Close()
+
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(conn, preface); err != nil {
+		return
+	}
+
+	fr := http2.NewFramer(conn, conn)
+	if err := fr.WriteSettings(); err != nil {
+		return
+	}
+
+	bodies := make(map[uint32]*bytes.Buffer)
+
+	for {
+		frame, err := fr.ReadFrame()
+		if err != nil {
+			return
+		}
+
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				_ = fr.WriteSettingsAck()
+			}
+		case *http2.PingFrame:
+			if !f.IsAck() && (s.ignorePings == nil || !s.ignorePings(index)) {
+				_ = fr.WritePing(true, f.Data)
+			}
+		case *http2.HeadersFrame:
+			bodies[f.StreamID] = &bytes.Buffer{}
+			if f.StreamEnded() {
+				s.onRequest(index, f.StreamID, nil, fr)
+			}
+		case *http2.DataFrame:
+			if body, ok := bodies[f.StreamID]; ok {
+				body.Write(f.Data())
+				if f.StreamEnded() {
+					s.onRequest(index, f.StreamID, body.Bytes(), fr)
+				}
+			}
+		}
+	}
+}
+
+func (s *rawH2CServer) URL() string {
+	return "http://" + s.listener.Addr().String()
+}
+
+func (s *rawH2CServer) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+func (s *rawH2CServer) Close() {
+	_ = s.listener.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func writeRawH2Response(fr *http2.Framer, streamId uint32) {
+	var headers bytes.Buffer
+	_ = hpack.NewEncoder(&headers).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+
+	_ = fr.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamId,
+		BlockFragment: headers.Bytes(),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	fhttp2 "github.com/bogdanfinn/fhttp/http2"
+	tls "github.com/bogdanfinn/utls"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+)
+
+func TestClient_DefaultH2SettingsOrderIsStable(t *testing.T) {
+	listener, settingsOrders := newSettingsRecordingListener(t)
+	defer listener.Close()
+
+	profile := tls_client.NewClientProfile(tls.HelloChrome_107, nil, nil, nil, 0, nil)
+
+	expected := []http2.SettingID{
+		http2.SettingHeaderTableSize,
+		http2.SettingMaxConcurrentStreams,
+		http2.SettingInitialWindowSize,
+		http2.SettingMaxFrameSize,
+	}
+
+	for i := 0; i < 10; i++ {
+		client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(profile), tls_client.WithH2C(tls_client.H2CPriorKnowledge))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = client.Get("http://" + listener.Addr().String())
+
+		assert.Equal(t, expected, <-settingsOrders)
+	}
+}
+
+func TestClient_DefaultH2SettingsOverride(t *testing.T) {
+	listener, settingsOrders := newSettingsRecordingListener(t)
+	defer listener.Close()
+
+	defaults := tls_client.DefaultH2Settings
+	defer func() {
+		tls_client.DefaultH2Settings = defaults
+	}()
+
+	tls_client.DefaultH2Settings = tls_client.H2Settings{
+		Settings: map[fhttp2.SettingID]uint32{
+			fhttp2.SettingHeaderTableSize:      4096,
+			fhttp2.SettingEnablePush:           0,
+			fhttp2.SettingInitialWindowSize:    65535,
+			fhttp2.SettingMaxConcurrentStreams: 100,
+		},
+		// settings missing in the order follow sorted by id
+		Order: []fhttp2.SettingID{
+			fhttp2.SettingInitialWindowSize,
+			fhttp2.SettingEnablePush,
+		},
+	}
+
+	profile := tls_client.NewClientProfile(tls.HelloChrome_107, nil, nil, nil, 0, nil)
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(profile), tls_client.WithH2C(tls_client.H2CPriorKnowledge))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = client.Get("http://" + listener.Addr().String())
+
+	expected := []http2.SettingID{
+		http2.SettingInitialWindowSize,
+		http2.SettingEnablePush,
+		http2.SettingHeaderTableSize,
+		http2.SettingMaxConcurrentStreams,
+	}
+
+	assert.Equal(t, expected, <-settingsOrders)
+}
@@ -0,0 +1,282 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestClient_H2CPriorKnowledge(t *testing.T) {
+	testServer := newH2CServer()
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithH2C(tls_client.H2CPriorKnowledge))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(testServer.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		assert.Equal(t, 2, resp.ProtoMajor)
+		assert.Equal(t, "HTTP/2.0", string(body))
+	}
+}
+
+func TestClient_H2CPriorKnowledgeStartsWithStream1(t *testing.T) {
+	streamIds := make(chan uint32, 2)
+
+	server := newRawH2CServer(t, func(conn int, streamId uint32, body []byte, fr *http2.Framer) {
+		streamIds <- streamId
+		writeRawH2Response(fr, streamId)
+	}, nil)
+	defer server.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithH2C(tls_client.H2CPriorKnowledge))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	assert.Equal(t, uint32(1), <-streamIds)
+	assert.Equal(t, uint32(3), <-streamIds)
+	assert.Equal(t, 1, server.Connections())
+}
+
+func TestClient_H2CUpgrade(t *testing.T) {
+	testServer := newH2CServer()
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithH2C(tls_client.H2CUpgrade))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server hands the upgraded request to the handler as it was sent
+	for _, expectedProto := range []string{"HTTP/1.1", "HTTP/2.0"} {
+		resp, err := client.Get(testServer.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		assert.Equal(t, 2, resp.ProtoMajor)
+		assert.Equal(t, expectedProto, string(body))
+	}
+}
+
+func TestClient_H2CUpgradeSendsTheRequest(t *testing.T) {
+	requests := make(chan string, 4)
+
+	testServer := newH2CServerWithHandler(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, req *stdhttp.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		requests <- req.Method + " " + req.URL.Path
+
+		_, _ = w.Write(body)
+	}))
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithH2C(tls_client.H2CUpgrade))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, testServer.URL+"/upgrade", strings.NewReader("request body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, "request body", string(body))
+	assert.Equal(t, "POST /upgrade", <-requests)
+	assert.Len(t, requests, 0)
+}
+
+func TestClient_H2CUpgradeFallsBackToHttp1(t *testing.T) {
+	testServer := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, req *stdhttp.Request) {
+		_, _ = io.WriteString(w, req.Proto)
+	}))
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithH2C(tls_client.H2CUpgrade))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 1, resp.ProtoMajor)
+	assert.Equal(t, "HTTP/1.1", string(body))
+}
+
+func TestClient_H2CUsesProfileSettingsOrder(t *testing.T) {
+	listener, settingsOrders := newSettingsRecordingListener(t)
+	defer listener.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithH2C(tls_client.H2CPriorKnowledge))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the listener closes the connection after the SETTINGS frame
+	_, _ = client.Get("http://" + listener.Addr().String())
+
+	expected := []http2.SettingID{
+		http2.SettingHeaderTableSize,
+		http2.SettingEnablePush,
+		http2.SettingMaxConcurrentStreams,
+		http2.SettingInitialWindowSize,
+		http2.SettingMaxHeaderListSize,
+	}
+
+	assert.Equal(t, expected, <-settingsOrders)
+}
+
+// newH2CServer starts a cleartext server which speaks h2c with prior knowledge and upgrade and writes the request protocol.
+func newH2CServer() *httptest.Server {
+	return newH2CServerWithHandler(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, req *stdhttp.Request) {
+		_, _ = io.WriteString(w, req.Proto)
+	}))
+}
+
+type settingsAckedKey struct{}
+
+// newH2CServerWithHandler starts a cleartext server which speaks h2c with prior knowledge and upgrade.
+// The server of x/net runs the handler of an upgraded request before it applied the SETTINGS of the client,
+// which it races with writing the response headers, so the upgraded request is answered once the SETTINGS are acknowledged.
+func newH2CServerWithHandler(handler stdhttp.Handler) *httptest.Server {
+	h2cHandler := h2c.NewHandler(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, req *stdhttp.Request) {
+		if req.ProtoMajor == 1 {
+			<-req.Context().Value(settingsAckedKey{}).(*settingsAckConn).acked
+		}
+
+		handler.ServeHTTP(w, req)
+	}), &http2.Server{})
+
+	server := httptest.NewUnstartedServer(h2cHandler)
+	server.Listener = &settingsAckListener{Listener: server.Listener}
+	server.Config.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		return context.WithValue(ctx, settingsAckedKey{}, conn)
+	}
+	server.Start()
+
+	return server
+}
+
+type settingsAckListener struct {
+	net.Listener
+}
+
+func (l *settingsAckListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &settingsAckConn{Conn: conn, acked: make(chan struct{})}, nil
+}
+
+// settingsAckConn closes acked once the server wrote a SETTINGS frame with the ACK flag.
+type settingsAckConn struct {
+	net.Conn
+	once  sync.Once
+	acked chan struct{}
+}
+
+func (c *settingsAckConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+
+	if bytes.Contains(p[:n], []byte{0, 0, 0, byte(http2.FrameSettings), byte(http2.FlagSettingsAck), 0, 0, 0, 0}) {
+		c.once.Do(func() { close(c.acked) })
+	}
+
+	return n, err
+}
+
+// newSettingsRecordingListener reads the first SETTINGS frame of every h2c prior knowledge connection and closes it.
+func newSettingsRecordingListener(t *testing.T) (net.Listener, <-chan []http2.SettingID) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settingsOrders := make(chan []http2.SettingID, 16)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			settingsOrders <- readSettingsOrder(conn)
+			_ = conn.Close()
+		}
+	}()
+
+	return listener, settingsOrders
+}
+
+func readSettingsOrder(conn net.Conn) []http2.SettingID {
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(conn, preface); err != nil {
+		return nil
+	}
+
+	frame, err := http2.NewFramer(conn, conn).ReadFrame()
+	if err != nil {
+		return nil
+	}
+
+	var order []http2.SettingID
+	if settings, ok := frame.(*http2.SettingsFrame); ok {
+		_ = settings.ForeachSetting(func(setting http2.Setting) error {
+			order = append(order, setting.ID)
+			return nil
+		})
+	}
+
+	return order
+}
@@ -14,6 +14,7 @@ import (
 func TestClient_HeaderOrder(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Chrome_105),
+		tls_client.WithInsecureSkipVerify(),
 	}
 
 	client, err := tls_client.NewHttpClient(nil, options...)
@@ -21,7 +22,7 @@ func TestClient_HeaderOrder(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
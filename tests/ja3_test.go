@@ -1,6 +1,8 @@
 package tests
 
 import (
+	"sort"
+	"strings"
 	"testing"
 
 	utls "github.com/bogdanfinn/utls"
@@ -107,3 +109,88 @@ func ja3_opera_91(t *testing.T) {
 	assert.Equal(t, len(spec.CipherSuites), 15, "Client should have 15 CipherSuites")
 	assert.Equal(t, len(spec.Extensions), 16, "Client should have 16 extensions")
 }
+
+func TestJA3_BrowserFingerprints(t *testing.T) {
+	compared := 0
+
+	for _, info := range tls_client.ListProfiles() {
+		profile, err := tls_client.LookupProfile(info.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id := profile.GetClientHelloId()
+		for _, fingerprints := range browserFingerprints {
+			expected, ok := fingerprints[id.Str()]
+			if !ok {
+				continue
+			}
+
+			ja3, err := profile.JA3()
+			if err != nil {
+				t.Fatalf("%s: %v", info.Name, err)
+			}
+
+			hash, err := profile.JA3Hash()
+			if err != nil {
+				t.Fatalf("%s: %v", info.Name, err)
+			}
+
+			assert.Equal(t, expected[ja3String], ja3, info.Name)
+			assert.Equal(t, expected[ja3Hash], hash, info.Name)
+			compared++
+		}
+	}
+
+	assert.NotZero(t, compared)
+}
+
+func TestJA3_SpecFactoryFromJa3String(t *testing.T) {
+	input := browserFingerprints[firefox][utls.HelloFirefox_105.Str()][ja3String]
+
+	specFactory, err := tls_client.GetSpecFactoryFromJa3String(input, []string{"PKCS1WithSHA256"}, []string{"1.3", "1.2"}, []string{"X25519"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := tls_client.NewClientProfile(utls.ClientHelloID{Client: "Custom", Version: "1", SpecFactory: specFactory}, nil, nil, nil, 0, nil)
+
+	ja3, err := profile.JA3()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, input, ja3)
+}
+
+func TestJA3_RandomExtensionOrder(t *testing.T) {
+	expected, err := tls_client.Chrome_107.JA3()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := tls_client.ProfileFromClientHello(captureClientHelloRecord(t, utls.HelloChrome_107, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ja3, err := imported.JA3()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedParts, parts := strings.Split(expected, ","), strings.Split(ja3, ",")
+
+	// only the order of the extensions differs, padding stays last
+	assert.Equal(t, expectedParts[0:2], parts[0:2])
+	assert.Equal(t, expectedParts[3:], parts[3:])
+	assert.Equal(t, sortedList(expectedParts[2]), sortedList(parts[2]))
+	assert.True(t, strings.HasSuffix(parts[2], "-21"))
+}
+
+func sortedList(list string) []string {
+	values := strings.Split(list, "-")
+	sort.Strings(values)
+
+	return values
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	utls "github.com/bogdanfinn/utls"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJA4_Chrome(t *testing.T) {
+	ja4, err := tls_client.Chrome_107.JA4()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cipher suites of chrome have a well known hash
+	assert.True(t, strings.HasPrefix(ja4, "t13d1516h2_8daaf6152771_"), ja4)
+
+	raw, err := tls_client.Chrome_107.JA4Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ja3Parts := strings.Split(browserFingerprints[chrome][utls.HelloChrome_107.Str()][ja3String], ",")
+
+	expectedRaw := strings.Join([]string{
+		"t13d1516h2",
+		strings.Join(sortedHex(ja3Parts[1], nil), ","),
+		strings.Join(sortedHex(ja3Parts[2], map[string]bool{"0": true, "16": true}), ","),
+		"0403,0804,0401,0503,0805,0501,0806,0601",
+	}, "_")
+
+	assert.Equal(t, expectedRaw, raw)
+}
+
+func TestJA4_HashAndMatch(t *testing.T) {
+	for _, profile := range []tls_client.ClientProfile{tls_client.Chrome_107, tls_client.Firefox_106, tls_client.Safari_16_0} {
+		ja4, err := profile.JA4()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		raw, err := profile.JA4Raw()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		original, err := profile.JA4RawOriginal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, fingerprint := range []string{ja4, raw, original} {
+			hashed, err := tls_client.HashJA4(fingerprint)
+			if assert.NoError(t, err) {
+				assert.Equal(t, ja4, hashed)
+			}
+
+			matches, err := profile.MatchesJA4(fingerprint)
+			if assert.NoError(t, err) {
+				assert.True(t, matches, fingerprint)
+			}
+		}
+	}
+
+	firefox, err := tls_client.Firefox_106.JA4()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := tls_client.Chrome_107.MatchesJA4(firefox)
+	assert.NoError(t, err)
+	assert.False(t, matches)
+
+	_, err = tls_client.HashJA4("771,4865-4866,0-23,29,0")
+	assert.Error(t, err)
+}
+
+func TestJA4H(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header = http.Header{
+		"accept":          {"*/*"},
+		"accept-language": {"de-DE,de;q=0.9"},
+		"user-agent":      {"test"},
+		"cookie":          {"b=2; a=1"},
+		"referer":         {"https://example.com/start"},
+		http.HeaderOrderKey: {
+			"user-agent",
+			"accept",
+			"cookie",
+			"referer",
+			"accept-language",
+		},
+	}
+
+	raw := tls_client.Chrome_107.JA4HRaw(req)
+	assert.Equal(t, "ge20cr03dede_user-agent,accept,accept-language_a,b_a=1,b=2", raw)
+
+	hashed, err := tls_client.HashJA4H(raw)
+	if assert.NoError(t, err) {
+		assert.Equal(t, tls_client.Chrome_107.JA4H(req), hashed)
+	}
+
+	req.Header = http.Header{"accept": {"*/*"}}
+
+	assert.True(t, strings.HasPrefix(tls_client.Chrome_107.JA4H(req), "ge20nn010000_"))
+	assert.True(t, strings.HasSuffix(tls_client.Chrome_107.JA4H(req), "_000000000000_000000000000"))
+}
+
+func TestJA4H_Http1Profile(t *testing.T) {
+	clientHelloId := utls.ClientHelloID{
+		Client:  "Http1Only",
+		Version: "1",
+		SpecFactory: func() (utls.ClientHelloSpec, error) {
+			return utls.ClientHelloSpec{
+				CipherSuites: []uint16{utls.TLS_AES_128_GCM_SHA256},
+				Extensions:   []utls.TLSExtension{&utls.ALPNExtension{AlpnProtocols: []string{"http/1.1"}}},
+			}, nil
+		},
+	}
+	profile := tls_client.NewClientProfile(clientHelloId, nil, nil, nil, 0, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header = http.Header{
+		"accept":            {"*/*"},
+		"connection":        {"keep-alive"},
+		http.HeaderOrderKey: {"accept", "connection"},
+	}
+
+	// connection specific headers are sent over http1
+	assert.Equal(t, "ge11nn020000_accept,connection__", profile.JA4HRaw(req))
+	assert.True(t, strings.HasPrefix(tls_client.Chrome_107.JA4HRaw(req), "ge20nn010000_accept_"))
+}
+
+// sortedHex converts a dash separated list of decimal values to sorted 4 digit hex values.
+func sortedHex(list string, exclude map[string]bool) []string {
+	var values []string
+	for _, value := range strings.Split(list, "-") {
+		if exclude[value] {
+			continue
+		}
+
+		n, _ := strconv.Atoi(value)
+		values = append(values, fmt.Sprintf("%04x", n))
+	}
+
+	sort.Strings(values)
+
+	return values
+}
@@ -0,0 +1,25 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Digman/tls-client/testserver"
+)
+
+func TestMain(m *testing.M) {
+	server, err := testserver.NewServer()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fingerprintApiEndpoint = server.URL() + "/api/all"
+
+	code := m.Run()
+
+	_ = server.Close()
+
+	os.Exit(code)
+}
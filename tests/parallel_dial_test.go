@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_HandshakesToDifferentHostsRunInParallel(t *testing.T) {
+	const hosts = 8
+	const delay = 300 * time.Millisecond
+
+	var forwarders []*slowForwarder
+	for i := 0; i < hosts; i++ {
+		testServer := newTLSTestServer()
+		defer testServer.Close()
+
+		forwarder := newSlowForwarder(t, testServer.Listener.Addr().String(), delay)
+		defer forwarder.Close()
+
+		forwarders = append(forwarders, forwarder)
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for _, forwarder := range forwarders {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+
+			resp, err := client.Get("https://" + addr)
+			if assert.NoError(t, err) {
+				_ = resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+		}(forwarder.Addr())
+	}
+	wg.Wait()
+
+	assert.Less(t, time.Since(start), hosts*delay/2)
+}
+
+func TestClient_ConcurrentRequestsToOneHostShareTheFirstHandshake(t *testing.T) {
+	testServer := newTLSTestServer()
+	defer testServer.Close()
+
+	forwarder := newSlowForwarder(t, testServer.Listener.Addr().String(), 100*time.Millisecond)
+	defer forwarder.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Get("https://" + forwarder.Addr())
+			if assert.NoError(t, err) {
+				_ = resp.Body.Close()
+				assert.Equal(t, 2, resp.ProtoMajor)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, forwarder.Connections())
+}
+
+func BenchmarkClient_HandshakesToManyHosts(b *testing.B) {
+	const hosts = 32
+
+	var addrs []string
+	for i := 0; i < hosts; i++ {
+		testServer := newTLSTestServer()
+		defer testServer.Close()
+
+		forwarder := newSlowForwarder(b, testServer.Listener.Addr().String(), 20*time.Millisecond)
+		defer forwarder.Close()
+
+		addrs = append(addrs, forwarder.Addr())
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify())
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		for _, addr := range addrs {
+			wg.Add(1)
+			go func(addr string) {
+				defer wg.Done()
+
+				resp, err := client.Get("https://" + addr)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				_ = resp.Body.Close()
+			}(addr)
+		}
+		wg.Wait()
+	}
+}
+
+func newTLSTestServer() *httptest.Server {
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	testServer.EnableHTTP2 = true
+	testServer.StartTLS()
+
+	return testServer
+}
+
+// slowForwarder forwards tcp connections to a target after a delay, simulating a slow network or proxy.
+type slowForwarder struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	connections int
+}
+
+func newSlowForwarder(tb testing.TB, target string, delay time.Duration) *slowForwarder {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	f := &slowForwarder{listener: listener}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			f.mu.Lock()
+			f.connections++
+			f.mu.Unlock()
+
+			go func() {
+				defer conn.Close()
+
+				time.Sleep(delay)
+
+				targetConn, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+
+				go func() {
+					_, _ = io.Copy(targetConn, conn)
+					_ = targetConn.Close()
+				}()
+
+				_, _ = io.Copy(conn, targetConn)
+			}()
+		}
+	}()
+
+	return f
+}
+
+func (f *slowForwarder) Addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *slowForwarder) Connections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.connections
+}
+
+func (f *slowForwarder) Close() {
+	_ = f.listener.Close()
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileBuilder_ChangesOnlyGivenFields(t *testing.T) {
+	profile := tls_client.ProfileFrom(tls_client.Chrome_107).
+		WithConnectionFlow(12517377).
+		WithPseudoHeaderOrder([]string{":method", ":path", ":authority", ":scheme"}).
+		Build()
+
+	assert.Equal(t, uint32(12517377), profile.GetConnectionFlow())
+	assert.Equal(t, []string{":method", ":path", ":authority", ":scheme"}, profile.GetPseudoHeaderOrder())
+
+	assert.Equal(t, tls_client.Chrome_107.GetClientHelloId().Client, profile.GetClientHelloId().Client)
+	assert.Equal(t, tls_client.Chrome_107.GetClientHelloId().Version, profile.GetClientHelloId().Version)
+	assert.Equal(t, tls_client.Chrome_107.GetSettings(), profile.GetSettings())
+	assert.Equal(t, tls_client.Chrome_107.GetSettingsOrder(), profile.GetSettingsOrder())
+	assert.Equal(t, tls_client.Chrome_107.GetPriorities(), profile.GetPriorities())
+
+	assert.Equal(t, uint32(15663105), tls_client.Chrome_107.GetConnectionFlow())
+}
+
+func TestProfileBuilder_SettingsAndPriorities(t *testing.T) {
+	settings := map[http2.SettingID]uint32{
+		http2.SettingHeaderTableSize:   65536,
+		http2.SettingInitialWindowSize: 131072,
+	}
+	order := []http2.SettingID{http2.SettingInitialWindowSize, http2.SettingHeaderTableSize}
+	priorities := []http2.Priority{{StreamID: 3, PriorityParam: http2.PriorityParam{Weight: 200}}}
+
+	profile := tls_client.ProfileFrom(tls_client.Firefox_106).WithSettings(settings, order).WithPriorities(priorities).Build()
+
+	assert.Equal(t, settings, profile.GetSettings())
+	assert.Equal(t, order, profile.GetSettingsOrder())
+	assert.Equal(t, priorities, profile.GetPriorities())
+
+	// the arguments are copied
+	settings[http2.SettingEnablePush] = 0
+	order[0] = http2.SettingEnablePush
+	priorities[0].StreamID = 5
+
+	assert.Len(t, profile.GetSettings(), 2)
+	assert.Equal(t, http2.SettingInitialWindowSize, profile.GetSettingsOrder()[0])
+	assert.Equal(t, uint32(3), profile.GetPriorities()[0].StreamID)
+}
+
+func TestProfileBuilder_AccessorsReturnCopies(t *testing.T) {
+	settings := tls_client.Chrome_107.GetSettings()
+	settings[http2.SettingHeaderTableSize] = 1
+
+	order := tls_client.Chrome_107.GetSettingsOrder()
+	order[0] = http2.SettingMaxFrameSize
+
+	pseudoHeaderOrder := tls_client.Chrome_107.GetPseudoHeaderOrder()
+	pseudoHeaderOrder[0] = ":path"
+
+	priorities := tls_client.Firefox_106.GetPriorities()
+	priorities[0].StreamID = 99
+
+	assert.Equal(t, uint32(65536), tls_client.Chrome_107.GetSettings()[http2.SettingHeaderTableSize])
+	assert.Equal(t, http2.SettingHeaderTableSize, tls_client.Chrome_107.GetSettingsOrder()[0])
+	assert.Equal(t, ":method", tls_client.Chrome_107.GetPseudoHeaderOrder()[0])
+	assert.NotEqual(t, uint32(99), tls_client.Firefox_106.GetPriorities()[0].StreamID)
+}
+
+func TestProfileBuilder_BuildDoesNotShareState(t *testing.T) {
+	builder := tls_client.ProfileFrom(tls_client.Chrome_107)
+
+	first := builder.Build()
+	second := builder.WithSettings(nil, nil).Build()
+
+	assert.NotNil(t, first.GetSettings())
+	assert.Nil(t, second.GetSettings())
+	assert.Nil(t, second.GetSettingsOrder())
+}
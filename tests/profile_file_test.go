@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileFile_RoundTripsBuiltInProfiles(t *testing.T) {
+	for _, info := range tls_client.ListProfiles() {
+		profile, err := tls_client.LookupProfile(info.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, format := range []tls_client.ProfileFormat{tls_client.ProfileFormatJSON, tls_client.ProfileFormatYAML} {
+			data, err := tls_client.MarshalProfile(profile, format)
+			if err != nil {
+				t.Fatalf("%s: %v", info.Name, err)
+			}
+
+			loaded, err := tls_client.LoadProfile(data)
+			if err != nil {
+				t.Fatalf("%s: %v", info.Name, err)
+			}
+
+			reencoded, err := tls_client.MarshalProfile(loaded, format)
+			if err != nil {
+				t.Fatalf("%s: %v", info.Name, err)
+			}
+
+			assert.Equal(t, string(data), string(reencoded), info.Name)
+
+			profileId, loadedId := profile.GetClientHelloId(), loaded.GetClientHelloId()
+			assert.Equal(t, profileId.Str(), loadedId.Str(), info.Name)
+			assertSameProfile(t, info.Name, profile, loaded)
+		}
+	}
+}
+
+func TestProfileFile_KeepsBuiltInClientHelloIds(t *testing.T) {
+	data, err := tls_client.MarshalProfile(tls_client.Chrome_107, tls_client.ProfileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := tls_client.LoadProfile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedId, loadedId := tls_client.Chrome_107.GetClientHelloId(), loaded.GetClientHelloId()
+	assert.Equal(t, expectedId.Str(), loadedId.Str())
+	assertSameProfile(t, "chrome_107", tls_client.Chrome_107, loaded)
+}
+
+func TestProfileFile_WritesSentSettingsOnly(t *testing.T) {
+	settings := map[http2.SettingID]uint32{
+		http2.SettingHeaderTableSize:   65536,
+		http2.SettingInitialWindowSize: 131072,
+		http2.SettingMaxFrameSize:      16384,
+	}
+	profile := tls_client.ProfileFrom(tls_client.Firefox_106).
+		WithSettings(settings, []http2.SettingID{http2.SettingInitialWindowSize, http2.SettingHeaderTableSize}).
+		Build()
+
+	data, err := tls_client.MarshalProfile(profile, tls_client.ProfileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := tls_client.LoadProfile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// MAX_FRAME_SIZE is missing in the order, so the profile does not send it
+	assert.NotContains(t, string(data), "MAX_FRAME_SIZE")
+	assert.Equal(t, "4:131072,1:65536|12517377|3:0:0:201,5:0:0:101,7:0:0:1,9:0:7:1,11:0:3:1,13:0:0:241|m,p,a,s", loaded.AkamaiFingerprint())
+	assert.Equal(t, profile.AkamaiFingerprint(), loaded.AkamaiFingerprint())
+}
+
+func TestProfileFile_LoadYAML(t *testing.T) {
+	data := `
+clientHello:
+  client: MyClient
+  version: "1"
+  cipherSuites: [GREASE, TLS_AES_128_GCM_SHA256, 49195]
+  compressionMethods: [0]
+  extensions:
+    - name: grease
+    - name: server_name
+    - name: supported_groups
+      groups: [GREASE, X25519, P256]
+    - name: application_layer_protocol_negotiation
+      protocols: [h2, http/1.1]
+    - name: key_share
+      keyShares:
+        - group: GREASE
+          data: "00"
+        - group: X25519
+    - name: supported_versions
+      versions: [GREASE, "1.3", "1.2"]
+    - name: compress_certificate
+      algorithms: [brotli]
+    - name: padding
+      paddingStyle: boringssl
+http2:
+  settings:
+    - id: HEADER_TABLE_SIZE
+      value: 65536
+    - id: INITIAL_WINDOW_SIZE
+      value: 6291456
+  connectionFlow: 15663105
+  pseudoHeaderOrder: [":method", ":authority", ":scheme", ":path"]
+`
+
+	profile, err := tls_client.LoadProfile([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := profile.GetClientHelloSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []uint16{0x0a0a, 0x1301, 0xc02b}, spec.CipherSuites)
+	assert.Len(t, spec.Extensions, 8)
+
+	json, err := tls_client.MarshalProfile(profile, tls_client.ProfileFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// numbers are written by their names if they have one
+	assert.Contains(t, string(json), `"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"`)
+	assert.Contains(t, string(json), `"connectionFlow": 15663105`)
+}
+
+func TestProfileFile_RejectsChangedUtlsClientHello(t *testing.T) {
+	data := `
+clientHello:
+  client: Chrome
+  version: "107"
+  cipherSuites: [TLS_AES_128_GCM_SHA256]
+  compressionMethods: [0]
+  extensions:
+    - name: server_name
+`
+
+	_, err := tls_client.LoadProfile([]byte(data))
+
+	assert.ErrorContains(t, err, "differs from the one of utls")
+}
+
+func TestProfileFile_RejectsUnknownFields(t *testing.T) {
+	_, err := tls_client.LoadProfile([]byte(`{"clientHello": {"client": "MyClient", "ciphers": []}}`))
+	assert.Error(t, err)
+
+	_, err = tls_client.LoadProfile([]byte("clientHello:\n  client: MyClient\n  extensions:\n    - name: unknown_extension\n"))
+	assert.ErrorContains(t, err, "unknown extension")
+	assert.False(t, errors.Is(err, tls_client.ErrUnknownProfile))
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupProfile(t *testing.T) {
+	profile, err := tls_client.LookupProfile("chrome_105")
+	if assert.NoError(t, err) {
+		assertSameProfile(t, "chrome_105", tls_client.Chrome_105, profile)
+	}
+
+	profile, err = tls_client.LookupProfile("firefox_latest")
+	if assert.NoError(t, err) {
+		assertSameProfile(t, "firefox_106", tls_client.Firefox_106, profile)
+	}
+
+	info, err := tls_client.LookupProfileInfo("chrome_latest")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "chrome_107", info.Name)
+		assert.Equal(t, "chrome", info.Browser)
+		assert.Equal(t, "107", info.Version)
+		assert.True(t, info.Http2)
+		assert.False(t, info.ReleaseDate.IsZero())
+		assert.Equal(t, []string{"chrome_latest"}, info.Aliases)
+	}
+}
+
+func TestLookupProfileUnknown(t *testing.T) {
+	_, err := tls_client.LookupProfile("chrome_1007")
+
+	assert.True(t, errors.Is(err, tls_client.ErrUnknownProfile))
+	assert.Contains(t, err.Error(), "chrome_1007")
+}
+
+func TestRegisterProfile(t *testing.T) {
+	info := tls_client.ProfileInfo{Name: "test_register_profile", Browser: "test", Version: "1", Aliases: []string{"test_register_latest"}}
+
+	assert.NoError(t, tls_client.RegisterProfile(tls_client.Chrome_105, info))
+
+	profile, err := tls_client.LookupProfile("test_register_latest")
+	if assert.NoError(t, err) {
+		assertSameProfile(t, "chrome_105", tls_client.Chrome_105, profile)
+	}
+
+	// names and aliases can not be registered twice
+	assert.Error(t, tls_client.RegisterProfile(tls_client.Chrome_106, info))
+	assert.Error(t, tls_client.RegisterProfile(tls_client.Chrome_106, tls_client.ProfileInfo{Name: "chrome_latest"}))
+	assert.Error(t, tls_client.RegisterProfile(tls_client.Chrome_106, tls_client.ProfileInfo{Name: "test_register_other", Aliases: []string{"firefox_106"}}))
+
+	// an alias can be moved to another profile, but not onto a profile name or to an unknown profile
+	assert.NoError(t, tls_client.RegisterProfileAlias("test_register_latest", "chrome_106"))
+	assert.Error(t, tls_client.RegisterProfileAlias("chrome_105", "chrome_106"))
+	assert.True(t, errors.Is(tls_client.RegisterProfileAlias("test_register_latest", "unknown"), tls_client.ErrUnknownProfile))
+
+	profile, err = tls_client.LookupProfile("test_register_latest")
+	if assert.NoError(t, err) {
+		assertSameProfile(t, "chrome_106", tls_client.Chrome_106, profile)
+	}
+}
+
+func TestListProfiles(t *testing.T) {
+	profiles := tls_client.ListProfiles()
+
+	names := make(map[string]bool)
+	for i, info := range profiles {
+		names[info.Name] = true
+
+		if i > 0 {
+			assert.Less(t, profiles[i-1].Name, info.Name)
+		}
+	}
+
+	// every deprecated mapped client is registered as well
+	for name := range tls_client.MappedTLSClients {
+		assert.True(t, names[name], name)
+	}
+}
+
+func TestProfileRegistryConcurrentAccess(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("test_concurrent_%d", i)
+			assert.NoError(t, tls_client.RegisterProfile(tls_client.Chrome_107, tls_client.ProfileInfo{Name: name}))
+
+			_, err := tls_client.LookupProfile(name)
+			assert.NoError(t, err)
+
+			_ = tls_client.ListProfiles()
+		}(i)
+	}
+
+	wg.Wait()
+}
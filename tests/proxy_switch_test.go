@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SetProxyDrainsPreviousProxyAfterInFlightRequests(t *testing.T) {
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	firstProxy := newConnectProxy(t)
+	defer firstProxy.Close()
+
+	secondProxy := newConnectProxy(t)
+	defer secondProxy.Close()
+
+	options := []tls_client.HttpClientOption{
+		tls_client.WithClientProfile(tls_client.Chrome_105),
+		tls_client.WithProxyUrl(fmt.Sprintf("http://%s", firstProxy.Addr())),
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slowResult := make(chan error)
+	go func() {
+		resp, err := client.Get(testServer.URL + "/slow")
+		if err == nil {
+			_, err = ioutil.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+		}
+		slowResult <- err
+	}()
+
+	assert.Eventually(t, func() bool { return firstProxy.OpenConnections() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	if err := client.SetProxy(fmt.Sprintf("http://%s", secondProxy.Addr())); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, secondProxy.ConnectedHosts(), 1)
+
+	// the in-flight request keeps its connection until it is finished
+	assert.Equal(t, 1, firstProxy.OpenConnections())
+
+	close(release)
+	assert.NoError(t, <-slowResult)
+
+	assert.Eventually(t, func() bool { return firstProxy.OpenConnections() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, secondProxy.OpenConnections())
+}
+
+func TestClient_SetProxyWithWarmProxyPools(t *testing.T) {
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	firstProxy := newConnectProxy(t)
+	defer firstProxy.Close()
+
+	secondProxy := newConnectProxy(t)
+	defer secondProxy.Close()
+
+	options := []tls_client.HttpClientOption{
+		tls_client.WithClientProfile(tls_client.Chrome_105),
+		tls_client.WithProxyUrl(fmt.Sprintf("http://%s", firstProxy.Addr())),
+		tls_client.WithWarmProxyPools(),
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, proxyServer := range []*connectProxy{firstProxy, secondProxy, firstProxy, secondProxy} {
+		if err := client.SetProxy(fmt.Sprintf("http://%s", proxyServer.Addr())); err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Get(testServer.URL + "/index")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// switching back reuses the connection which was kept open
+	assert.Equal(t, 1, firstProxy.Connections())
+	assert.Equal(t, 1, secondProxy.Connections())
+}
+
+func TestClient_SetProxyToDirectAndBack(t *testing.T) {
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	proxyServer := newConnectProxy(t)
+	defer proxyServer.Close()
+
+	options := []tls_client.HttpClientOption{
+		tls_client.WithClientProfile(tls_client.Chrome_105),
+		tls_client.WithProxyUrl(fmt.Sprintf("http://%s", proxyServer.Addr())),
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, proxyUrl := range []string{"", fmt.Sprintf("http://%s", proxyServer.Addr())} {
+		if err := client.SetProxy(proxyUrl); err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Get(testServer.URL + "/index")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	assert.Len(t, proxyServer.ConnectedHosts(), 1)
+
+	err = client.SetProxy("ftp://invalid")
+	assert.Error(t, err)
+	assert.Equal(t, fmt.Sprintf("http://%s", proxyServer.Addr()), client.GetProxy())
+}
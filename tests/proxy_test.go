@@ -0,0 +1,615 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf16"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/md4"
+)
+
+type fakePacEvaluator struct {
+	proxyHost string
+	proxyAddr string
+}
+
+func (f fakePacEvaluator) FindProxyForURL(url string, host string) (string, error) {
+	if host == f.proxyHost {
+		return fmt.Sprintf("PROXY %s; DIRECT", f.proxyAddr), nil
+	}
+
+	return "DIRECT", nil
+}
+
+func TestClient_ProxyFuncSelectsProxyPerHost(t *testing.T) {
+	proxiedServer := getWebServer()
+	proxiedServer.Start()
+	defer proxiedServer.Close()
+
+	directServer := getWebServer()
+	directServer.Start()
+	defer directServer.Close()
+
+	proxyServer := newConnectProxy(t)
+	defer proxyServer.Close()
+
+	proxiedUrl, _ := url.Parse(proxiedServer.URL)
+
+	options := []tls_client.HttpClientOption{
+		tls_client.WithClientProfile(tls_client.Chrome_105),
+		tls_client.WithProxyFunc(func(req *http.Request) (*url.URL, error) {
+			if req.URL.Host == proxiedUrl.Host {
+				return url.Parse("http://" + proxyServer.Addr())
+			}
+
+			return nil, nil
+		}),
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{proxiedServer.URL, directServer.URL} {
+		resp, err := client.Get(target + "/index")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	assert.Equal(t, []string{proxiedUrl.Host}, proxyServer.ConnectedHosts())
+}
+
+func TestClient_ProxyPacSelectsProxyPerHost(t *testing.T) {
+	proxiedServer := getWebServer()
+	proxiedServer.Start()
+	defer proxiedServer.Close()
+
+	proxyServer := newConnectProxy(t)
+	defer proxyServer.Close()
+
+	proxiedUrl, _ := url.Parse(proxiedServer.URL)
+
+	options := []tls_client.HttpClientOption{
+		tls_client.WithClientProfile(tls_client.Chrome_105),
+		tls_client.WithProxyPac(fakePacEvaluator{proxyHost: proxiedUrl.Hostname(), proxyAddr: proxyServer.Addr()}),
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(proxiedServer.URL + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{proxiedUrl.Host}, proxyServer.ConnectedHosts())
+}
+
+// staticPacEvaluator returns the same PAC result for every request.
+type staticPacEvaluator string
+
+func (s staticPacEvaluator) FindProxyForURL(url string, host string) (string, error) {
+	return string(s), nil
+}
+
+func TestClient_ProxyPacFailsOverToTheNextEntry(t *testing.T) {
+	echoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.Copy(w, req.Body)
+	}))
+	defer echoServer.Close()
+
+	tlsServer := newTLSTestServer()
+	defer tlsServer.Close()
+
+	proxyServer := newConnectProxy(t)
+	defer proxyServer.Close()
+
+	closedAddr := closedPortAddr(t)
+
+	options := []tls_client.HttpClientOption{
+		tls_client.WithClientProfile(tls_client.Chrome_105),
+		tls_client.WithInsecureSkipVerify(),
+		tls_client.WithProxyPac(staticPacEvaluator(fmt.Sprintf("PROXY %s; PROXY %s; DIRECT", closedAddr, proxyServer.Addr()))),
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the body is sent again through the second proxy
+	req, err := http.NewRequest(http.MethodPost, echoServer.URL, strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "body", string(body))
+
+	resp, err = client.Get(tlsServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	echoUrl, _ := url.Parse(echoServer.URL)
+	tlsUrl, _ := url.Parse(tlsServer.URL)
+	assert.Equal(t, []string{echoUrl.Host, tlsUrl.Host}, proxyServer.ConnectedHosts())
+}
+
+func TestClient_ProxyPacFailsOverToDirect(t *testing.T) {
+	server := getWebServer()
+	server.Start()
+	defer server.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105), tls_client.WithProxyPac(staticPacEvaluator("PROXY "+closedPortAddr(t)+"; DIRECT")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(server.URL + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestClient_ProxyPacFailsWithTheLastEntry(t *testing.T) {
+	closedAddr := closedPortAddr(t)
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105), tls_client.WithProxyPac(staticPacEvaluator("PROXY "+closedAddr+"; PROXY "+closedAddr)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get("http://example.invalid/")
+
+	var dialErr *tls_client.DialError
+	assert.True(t, errors.As(err, &dialErr))
+}
+
+func TestProxyFuncFromPac_ReturnsTheFirstEntry(t *testing.T) {
+	proxyFunc := tls_client.ProxyFuncFromPac(staticPacEvaluator("PROXY a.example.com:8080; SOCKS b.example.com:1080; DIRECT"))
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxyUrl, err := proxyFunc(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "http://a.example.com:8080", proxyUrl.String())
+
+	_, err = tls_client.ProxyFuncFromPac(staticPacEvaluator("PROXY a.example.com:8080; FTP b.example.com:21"))(req)
+	assert.Error(t, err)
+}
+
+func TestProxyFuncFromEnvironment(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://http-proxy.example.com:8080")
+	t.Setenv("HTTPS_PROXY", "http://https-proxy.example.com:8443")
+	t.Setenv("NO_PROXY", ".internal.example.com,10.0.0.0/8")
+
+	proxyFunc := tls_client.ProxyFuncFromEnvironment()
+
+	expectations := map[string]string{
+		"http://example.com/":               "http://http-proxy.example.com:8080",
+		"https://example.com/":              "http://https-proxy.example.com:8443",
+		"https://api.internal.example.com/": "",
+		"http://10.1.2.3/":                  "",
+		"http://localhost:8080/":            "",
+	}
+
+	for target, expected := range expectations {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proxyUrl, err := proxyFunc(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected == "" {
+			assert.Nil(t, proxyUrl, target)
+			continue
+		}
+
+		if assert.NotNil(t, proxyUrl, target) {
+			assert.Equal(t, expected, proxyUrl.String(), target)
+		}
+	}
+}
+
+func TestClient_ProxyAuthentication(t *testing.T) {
+	for _, scheme := range []string{"Basic", "Digest", "NTLM"} {
+		t.Run(scheme, func(t *testing.T) {
+			testServer := getWebServer()
+			testServer.Start()
+			defer testServer.Close()
+
+			proxyServer := newConnectProxy(t)
+			proxyServer.requireAuth(scheme, "user", "secret")
+			defer proxyServer.Close()
+
+			options := []tls_client.HttpClientOption{
+				tls_client.WithClientProfile(tls_client.Chrome_105),
+				tls_client.WithProxyUrl(fmt.Sprintf("http://user:secret@%s", proxyServer.Addr())),
+			}
+
+			client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.Get(testServer.URL + "/index")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Len(t, proxyServer.ConnectedHosts(), 1)
+			// the whole handshake, including the ntlm negotiation, happens on one connection
+			assert.Equal(t, 1, proxyServer.Connections())
+		})
+	}
+}
+
+func TestClient_ProxyAuthenticationWrongPassword(t *testing.T) {
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	proxyServer := newConnectProxy(t)
+	proxyServer.requireAuth("Digest", "user", "secret")
+	defer proxyServer.Close()
+
+	options := []tls_client.HttpClientOption{
+		tls_client.WithClientProfile(tls_client.Chrome_105),
+		tls_client.WithProxyUrl(fmt.Sprintf("http://user:wrong@%s", proxyServer.Addr())),
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL + "/index")
+
+	assert.Nil(t, resp)
+	assert.Error(t, err)
+	assert.Empty(t, proxyServer.ConnectedHosts())
+}
+
+func TestClient_ProxyAuthenticationClosedConnection(t *testing.T) {
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	newClient := func(proxyServer *connectProxy) tls_client.HttpClient {
+		client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(),
+			tls_client.WithClientProfile(tls_client.Chrome_105),
+			tls_client.WithProxyUrl(fmt.Sprintf("http://user:secret@%s", proxyServer.Addr())),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return client
+	}
+
+	t.Run("Digest", func(t *testing.T) {
+		proxyServer := newConnectProxy(t)
+		proxyServer.requireAuth("Digest", "user", "secret")
+		proxyServer.closeOnChallenge = true
+		defer proxyServer.Close()
+
+		resp, err := newClient(proxyServer).Get(testServer.URL + "/index")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		// the credentials are sent on a new connection after the challenge closed the first one
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, proxyServer.Connections())
+	})
+
+	t.Run("NTLM", func(t *testing.T) {
+		proxyServer := newConnectProxy(t)
+		proxyServer.requireAuth("NTLM", "user", "secret")
+		proxyServer.closeOnChallenge = true
+		defer proxyServer.Close()
+
+		resp, err := newClient(proxyServer).Get(testServer.URL + "/index")
+
+		// the negotiate message is sent on a new connection, the closed ntlm challenge ends the handshake
+		assert.Nil(t, resp)
+		assert.ErrorContains(t, err, "proxy closed the connection during the ntlm handshake")
+		assert.Equal(t, 2, proxyServer.Connections())
+		assert.Empty(t, proxyServer.ConnectedHosts())
+	})
+}
+
+// connectProxy is a minimal http proxy which tunnels CONNECT requests and records the requested hosts.
+type connectProxy struct {
+	listener net.Listener
+
+	authScheme   string
+	authUser     string
+	authPassword string
+	// closeOnChallenge closes the connection after every 407 challenge
+	closeOnChallenge bool
+
+	mu              sync.Mutex
+	connectedHosts  []string
+	connections     int
+	openConnections int
+}
+
+func newConnectProxy(t *testing.T) *connectProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &connectProxy{listener: listener}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go p.handle(conn)
+		}
+	}()
+
+	return p
+}
+
+// requireAuth lets the proxy answer with a 407 challenge of the given scheme until valid credentials are sent.
+func (p *connectProxy) requireAuth(scheme string, user string, password string) {
+	p.authScheme = scheme
+	p.authUser = user
+	p.authPassword = password
+}
+
+func (p *connectProxy) handle(conn net.Conn) {
+	defer conn.Close()
+
+	p.mu.Lock()
+	p.connections++
+	p.openConnections++
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.openConnections--
+		p.mu.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+	ntlmChallenge := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil || req.Method != http.MethodConnect {
+			return
+		}
+
+		challenge, authorized := p.authorize(req.Header.Get("Proxy-Authorization"), ntlmChallenge)
+		if !authorized {
+			if p.closeOnChallenge {
+				_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: " + challenge + "\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"))
+				return
+			}
+
+			_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: " + challenge + "\r\nContent-Length: 0\r\n\r\n"))
+			continue
+		}
+
+		p.mu.Lock()
+		p.connectedHosts = append(p.connectedHosts, req.Host)
+		p.mu.Unlock()
+
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			_, _ = conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return
+		}
+		defer target.Close()
+
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+
+		go func() {
+			_, _ = io.Copy(target, reader)
+			_ = target.Close()
+		}()
+
+		_, _ = io.Copy(conn, target)
+
+		return
+	}
+}
+
+var authParamRegex = regexp.MustCompile(`(\w+)=("([^"]*)"|[^,\s]*)`)
+
+func (p *connectProxy) authorize(authorization string, ntlmChallenge []byte) (string, bool) {
+	switch p.authScheme {
+	case "":
+		return "", true
+	case "Basic":
+		expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(p.authUser+":"+p.authPassword))
+		return `Basic realm="proxy"`, authorization == expected
+	case "Digest":
+		challenge := `Digest realm="proxy", nonce="abc123", qop="auth", opaque="xyz", algorithm=MD5`
+		if !strings.HasPrefix(authorization, "Digest ") {
+			return challenge, false
+		}
+
+		params := make(map[string]string)
+		for _, match := range authParamRegex.FindAllStringSubmatch(authorization, -1) {
+			params[match[1]] = strings.Trim(match[2], `"`)
+		}
+
+		md5Hex := func(s string) string {
+			sum := md5.Sum([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+
+		ha1 := md5Hex(p.authUser + ":proxy:" + p.authPassword)
+		ha2 := md5Hex("CONNECT:" + params["uri"])
+		expected := md5Hex(ha1 + ":abc123:" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+
+		return challenge, params["username"] == p.authUser && params["opaque"] == "xyz" && params["response"] == expected
+	case "NTLM":
+		if !strings.HasPrefix(authorization, "NTLM ") {
+			return "NTLM", false
+		}
+
+		msg, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, "NTLM "))
+		if err != nil || len(msg) < 12 {
+			return "NTLM", false
+		}
+
+		switch binary.LittleEndian.Uint32(msg[8:]) {
+		case 1:
+			return "NTLM " + base64.StdEncoding.EncodeToString(ntlmChallengeMessage(ntlmChallenge)), false
+		case 3:
+			return "NTLM", verifyNtlmAuthenticateMessage(msg, p.authUser, p.authPassword, ntlmChallenge)
+		}
+	}
+
+	return p.authScheme, false
+}
+
+func ntlmChallengeMessage(serverChallenge []byte) []byte {
+	targetInfo := []byte{0, 0, 0, 0} // MsvAvEOL
+
+	msg := make([]byte, 48)
+	copy(msg, "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[20:], 0x00000001|0x00000200|0x00800000)
+	copy(msg[24:], serverChallenge)
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(msg[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(msg[44:], 48)
+
+	return append(msg, targetInfo...)
+}
+
+func verifyNtlmAuthenticateMessage(msg []byte, user string, password string, serverChallenge []byte) bool {
+	field := func(index int) []byte {
+		length := int(binary.LittleEndian.Uint16(msg[12+index*8:]))
+		offset := int(binary.LittleEndian.Uint32(msg[12+index*8+4:]))
+		if offset+length > len(msg) {
+			return nil
+		}
+		return msg[offset : offset+length]
+	}
+
+	ntResponse := field(1)
+	if len(ntResponse) < 16 || !bytes.Equal(field(3), utf16le(user)) {
+		return false
+	}
+
+	passwordHash := md4.New()
+	passwordHash.Write(utf16le(password))
+
+	mac := hmac.New(md5.New, passwordHash.Sum(nil))
+	mac.Write(utf16le(strings.ToUpper(user)))
+	ntowf := mac.Sum(nil)
+
+	mac = hmac.New(md5.New, ntowf)
+	mac.Write(serverChallenge)
+	mac.Write(ntResponse[16:])
+
+	return hmac.Equal(mac.Sum(nil), ntResponse[:16])
+}
+
+func utf16le(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(encoded))
+
+	for i, r := range encoded {
+		binary.LittleEndian.PutUint16(b[2*i:], r)
+	}
+
+	return b
+}
+
+func (p *connectProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+func (p *connectProxy) ConnectedHosts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.connectedHosts...)
+}
+
+func (p *connectProxy) Connections() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.connections
+}
+
+func (p *connectProxy) OpenConnections() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.openConnections
+}
+
+func (p *connectProxy) Close() {
+	_ = p.listener.Close()
+}
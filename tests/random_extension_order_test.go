@@ -16,6 +16,7 @@ import (
 func TestClient_RandomExtensionOrderChrome(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.Chrome_107),
+		tls_client.WithInsecureSkipVerify(),
 		tls_client.WithRandomTLSExtensionOrder(),
 	}
 
@@ -24,7 +25,7 @@ func TestClient_RandomExtensionOrderChrome(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -66,6 +67,7 @@ func TestClient_RandomExtensionOrderChrome(t *testing.T) {
 func TestClient_RandomExtensionOrderCustom(t *testing.T) {
 	options := []tls_client.HttpClientOption{
 		tls_client.WithClientProfile(tls_client.CloudflareCustom),
+		tls_client.WithInsecureSkipVerify(),
 		tls_client.WithRandomTLSExtensionOrder(),
 	}
 
@@ -74,7 +76,7 @@ func TestClient_RandomExtensionOrderCustom(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, peetApiEndpoint, nil)
+	req, err := http.NewRequest(http.MethodGet, fingerprintApiEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
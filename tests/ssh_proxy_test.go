@@ -0,0 +1,321 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestClient_SshProxyWithPassword(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	sshServer := newSshServer(t)
+	defer sshServer.Close()
+
+	proxyUrl := fmt.Sprintf("ssh://user:secret@%s?known_hosts=%s", sshServer.Addr(), url.QueryEscape(sshServer.knownHostsFile))
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105), tls_client.WithProxyUrl(proxyUrl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(testServer.URL + "/index")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	testServerUrl, _ := url.Parse(testServer.URL)
+	assert.Equal(t, []string{testServerUrl.Host}, sshServer.TunneledHosts())
+	assert.Equal(t, 1, sshServer.Connections())
+}
+
+func TestClient_SshProxyWithTLSTarget(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	testServer.EnableHTTP2 = true
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	sshServer := newSshServer(t)
+	defer sshServer.Close()
+
+	proxyUrl := fmt.Sprintf("ssh://user:secret@%s?known_hosts=%s", sshServer.Addr(), url.QueryEscape(sshServer.knownHostsFile))
+
+	options := []tls_client.HttpClientOption{
+		tls_client.WithClientProfile(tls_client.Chrome_107),
+		tls_client.WithProxyUrl(proxyUrl),
+		tls_client.WithInsecureSkipVerify(),
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Len(t, sshServer.TunneledHosts(), 1)
+}
+
+func TestClient_SshProxyWithIdentityFile(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	sshServer := newSshServer(t)
+	defer sshServer.Close()
+
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8Key, err := x509.MarshalPKCS8PrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBlock := &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Key}
+
+	identityFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := ioutil.WriteFile(identityFile, pem.EncodeToMemory(pemBlock), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshServer.authorizedKey = signer.PublicKey()
+
+	proxyUrl := fmt.Sprintf("ssh://user@%s?known_hosts=%s&identity_file=%s", sshServer.Addr(), url.QueryEscape(sshServer.knownHostsFile), url.QueryEscape(identityFile))
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105), tls_client.WithProxyUrl(proxyUrl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestClient_SshProxyRejectsUnknownHostKey(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	sshServer := newSshServer(t)
+	defer sshServer.Close()
+
+	emptyKnownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := ioutil.WriteFile(emptyKnownHosts, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	proxyUrl := fmt.Sprintf("ssh://user:secret@%s?known_hosts=%s", sshServer.Addr(), url.QueryEscape(emptyKnownHosts))
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_105), tls_client.WithProxyUrl(proxyUrl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL + "/index")
+
+	assert.Nil(t, resp)
+	assert.Error(t, err)
+	assert.Empty(t, sshServer.TunneledHosts())
+}
+
+// sshServer is an in-process ssh server which only supports direct-tcpip channels.
+type sshServer struct {
+	listener       net.Listener
+	config         *ssh.ServerConfig
+	knownHostsFile string
+	authorizedKey  ssh.PublicKey
+
+	mu            sync.Mutex
+	tunneledHosts []string
+	connections   int
+}
+
+func newSshServer(t *testing.T) *sshServer {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &sshServer{listener: listener}
+
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "user" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid password")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if s.authorizedKey != nil && string(key.Marshal()) == string(s.authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown public key")
+		},
+	}
+	s.config.AddHostKey(hostSigner)
+
+	s.knownHostsFile = filepath.Join(t.TempDir(), "known_hosts")
+	knownHostsLine := knownhosts.Line([]string{listener.Addr().String()}, hostSigner.PublicKey())
+	if err := ioutil.WriteFile(s.knownHostsFile, []byte(knownHostsLine+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.handle(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *sshServer) handle(conn net.Conn) {
+	_, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	s.connections++
+	s.mu.Unlock()
+
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only direct-tcpip is supported")
+			continue
+		}
+
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		addr := net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port)))
+
+		targetConn, err := net.Dial("tcp", addr)
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			_ = targetConn.Close()
+			continue
+		}
+
+		s.mu.Lock()
+		s.tunneledHosts = append(s.tunneledHosts, addr)
+		s.mu.Unlock()
+
+		go ssh.DiscardRequests(channelRequests)
+
+		go func() {
+			_, _ = io.Copy(targetConn, channel)
+			_ = targetConn.Close()
+		}()
+
+		go func() {
+			_, _ = io.Copy(channel, targetConn)
+			_ = channel.Close()
+		}()
+	}
+}
+
+func (s *sshServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *sshServer) TunneledHosts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.tunneledHosts...)
+}
+
+func (s *sshServer) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connections
+}
+
+func (s *sshServer) Close() {
+	_ = s.listener.Close()
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	tls_client "github.com/Digman/tls-client"
+	"github.com/Digman/tls-client/shared"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestServer_Http2(t *testing.T) {
+	response := requestFingerprint(t, http.MethodPost, tls_client.WithClientProfile(tls_client.Firefox_106), tls_client.WithInsecureSkipVerify())
+
+	assert.Equal(t, "h2", response.HTTPVersion)
+	assert.Equal(t, http.MethodPost, response.Method)
+	assert.Equal(t, "772", response.TLS.TLSVersionNegotiated)
+	assert.Equal(t, tls_client.Firefox_106.AkamaiFingerprint(), response.HTTP2.AkamaiFingerprint)
+
+	ja3, err := tls_client.Firefox_106.JA3()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ja3, response.TLS.Ja3)
+
+	var frameTypes []string
+	for _, frame := range response.HTTP2.SentFrames {
+		frameTypes = append(frameTypes, frame.FrameType)
+	}
+
+	assert.Equal(t, "SETTINGS,PRIORITY,PRIORITY,PRIORITY,PRIORITY,PRIORITY,PRIORITY,WINDOW_UPDATE,HEADERS,DATA", strings.Join(frameTypes, ","))
+	assert.Equal(t, []string{"HEADER_TABLE_SIZE = 65536", "INITIAL_WINDOW_SIZE = 131072", "MAX_FRAME_SIZE = 16384"}, response.HTTP2.SentFrames[0].Settings)
+	assert.Equal(t, ":method: POST", response.HTTP2.SentFrames[8].Headers[0])
+
+	var serverName string
+	for _, extension := range response.TLS.Extensions {
+		if extension.Name == "server_name (0)" {
+			serverName = extension.ServerName
+		}
+	}
+
+	assert.Equal(t, "localhost", serverName)
+}
+
+func TestTestServer_Http1(t *testing.T) {
+	response := requestFingerprint(t, http.MethodGet, tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify(), tls_client.WithForceHttp1())
+
+	assert.Equal(t, "HTTP/1.1", response.HTTPVersion)
+	assert.Equal(t, http.MethodGet, response.Method)
+	assert.Empty(t, response.HTTP2.SentFrames)
+	assert.Contains(t, response.TLS.Ciphers, "TLS_AES_128_GCM_SHA256")
+}
+
+func requestFingerprint(t *testing.T, method string, options ...tls_client.HttpClientOption) shared.TlsApiResponse {
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader("body")
+	}
+
+	req, err := http.NewRequest(method, fingerprintApiEndpoint, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	readBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := shared.TlsApiResponse{}
+	if err := json.Unmarshal(readBytes, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
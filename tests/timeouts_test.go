@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_TimeoutsTLSHandshake(t *testing.T) {
+	listener := newSilentListener(t)
+	defer listener.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithTimeouts(tls_client.Timeouts{
+		TLSHandshake: 200 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = client.Get("https://" + listener.Addr().String())
+
+	assertTimeoutPhase(t, err, tls_client.TimeoutPhaseTLSHandshake)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	var handshakeErr *tls_client.TLSHandshakeError
+	assert.True(t, errors.As(err, &handshakeErr))
+}
+
+func TestClient_TimeoutsContextDeadlineDuringTLSHandshake(t *testing.T) {
+	listener := newSilentListener(t)
+	defer listener.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithTimeouts(tls_client.Timeouts{
+		TLSHandshake: 10 * time.Second,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+listener.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Do(req)
+
+	// the deadline of the request expired, not the one of the handshake
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+
+	var timeoutErr *tls_client.TimeoutError
+	if errors.As(err, &timeoutErr) {
+		assert.NotEqual(t, tls_client.TimeoutPhaseTLSHandshake, timeoutErr.Phase)
+	}
+
+	var handshakeErr *tls_client.TLSHandshakeError
+	assert.True(t, errors.As(err, &handshakeErr))
+}
+
+func TestClient_TimeoutsProxyConnect(t *testing.T) {
+	proxyListener := newSilentListener(t)
+	defer proxyListener.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithProxyUrl("http://"+proxyListener.Addr().String()), tls_client.WithTimeouts(tls_client.Timeouts{
+		ProxyConnect: 200 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get("https://example.com")
+
+	assertTimeoutPhase(t, err, tls_client.TimeoutPhaseProxyConnect)
+}
+
+func TestClient_TimeoutsResponseHeader(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(time.Second)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, enableHttp2 := range []bool{false, true} {
+		testServer := httptest.NewUnstartedServer(handler)
+		testServer.EnableHTTP2 = enableHttp2
+		testServer.StartTLS()
+
+		client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify(), tls_client.WithTimeouts(tls_client.Timeouts{
+			ResponseHeader: 200 * time.Millisecond,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.Get(testServer.URL)
+
+		assertTimeoutPhase(t, err, tls_client.TimeoutPhaseResponseHeader)
+
+		testServer.Close()
+	}
+}
+
+func TestClient_TimeoutsRequest(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(time.Second)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithTimeouts(tls_client.Timeouts{
+		Request: 300 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = client.Get(testServer.URL)
+
+	assertTimeoutPhase(t, err, tls_client.TimeoutPhaseRequest)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestClient_TimeoutsIdleConnection(t *testing.T) {
+	var mu sync.Mutex
+	var connections int
+
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	testServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	testServer.EnableHTTP2 = true
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify(), tls_client.WithTimeouts(tls_client.Timeouts{
+		IdleConnection: 100 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(testServer.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		time.Sleep(300 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, 2, connections)
+}
+
+func assertTimeoutPhase(t *testing.T, err error, phase tls_client.TimeoutPhase) {
+	t.Helper()
+
+	var timeoutErr *tls_client.TimeoutError
+	if assert.True(t, errors.As(err, &timeoutErr), "expected a timeout error, got %v", err) {
+		assert.Equal(t, phase, timeoutErr.Phase)
+	}
+}
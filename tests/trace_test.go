@@ -0,0 +1,288 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tls_client "github.com/Digman/tls-client"
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/bogdanfinn/fhttp/httptest"
+	"github.com/bogdanfinn/fhttp/httptrace"
+	tls "github.com/bogdanfinn/utls"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ClientTrace(t *testing.T) {
+	testServer := newTLSTestServer()
+	defer testServer.Close()
+
+	events := &traceRecorder{}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify(), tls_client.WithClientTrace(events.trace()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, port, _ := net.SplitHostPort(testServer.Listener.Addr().String())
+
+	resp, err := client.Get("https://localhost:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	recorded := events.names()
+
+	// localhost may resolve to ::1 as well, which is not listened on
+	assert.Equal(t, []string{"DNSStart", "DNSDone", "ConnectStart", "ConnectDone"}, recorded[:4])
+	// the SETTINGS frame is read by the connection in parallel to the request
+	assert.Equal(t, []string{"TLSHandshakeStart", "TLSHandshakeDone", "GotConn", "GotFirstResponseByte"}, without(recorded[4:], "Connect", "H2SettingsReceived"))
+	assert.Contains(t, recorded, "H2SettingsReceived")
+
+	assert.NotNil(t, events.spec)
+	assert.NotEmpty(t, events.spec.Extensions)
+	assert.Equal(t, "h2", events.state.NegotiatedProtocol)
+	assert.NotEmpty(t, events.serverSettings)
+	assert.False(t, events.reused)
+}
+
+func TestClient_ClientTraceReportsDialedAddresses(t *testing.T) {
+	testServer := newTLSTestServer()
+	defer testServer.Close()
+
+	var mu sync.Mutex
+	var lookedUp []string
+	var connected []string
+
+	trace := &tls_client.ClientTrace{
+		DNSStart: func(host string) {
+			mu.Lock()
+			lookedUp = append(lookedUp, host)
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			connected = append(connected, addr)
+			mu.Unlock()
+		},
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify(), tls_client.WithClientTrace(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, port, _ := net.SplitHostPort(testServer.Listener.Addr().String())
+
+	resp, err := client.Get("https://localhost:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{"localhost"}, lookedUp)
+	// the dialer reports the resolved addresses it tries, the last one is the one it connected to
+	if assert.NotEmpty(t, connected) {
+		assert.Equal(t, "127.0.0.1:"+port, connected[len(connected)-1])
+	}
+}
+
+func TestClient_ClientTraceOfContext(t *testing.T) {
+	testServer := newTLSTestServer()
+	defer testServer.Close()
+
+	clientEvents := &traceRecorder{}
+	requestEvents := &traceRecorder{}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify(), tls_client.WithClientTrace(clientEvents.trace()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), tls_client.ContextKeyClientTrace{}, requestEvents.trace())
+
+	resp, err := client.GetContext(ctx, testServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Contains(t, requestEvents.names(), "TLSHandshakeDone")
+	assert.Contains(t, requestEvents.names(), "GotConn")
+	assert.NotContains(t, clientEvents.names(), "TLSHandshakeDone")
+	assert.NotContains(t, clientEvents.names(), "GotConn")
+}
+
+func TestClient_ClientTraceProxyConnect(t *testing.T) {
+	testServer := getWebServer()
+	testServer.Start()
+	defer testServer.Close()
+
+	proxyServer := newConnectProxy(t)
+	proxyServer.requireAuth("Basic", "user", "secret")
+	defer proxyServer.Close()
+
+	events := &traceRecorder{}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithProxyUrl(fmt.Sprintf("http://user:secret@%s", proxyServer.Addr())), tls_client.WithClientTrace(events.trace()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, []string{"ProxyConnectStart", "ConnectStart", "ConnectDone", "ProxyConnectDone", "GotConn", "GotFirstResponseByte"}, events.names())
+	assert.Equal(t, fmt.Sprintf("http://user:xxxxx@%s", proxyServer.Addr()), events.proxyUrl)
+}
+
+func TestClient_ClientTraceGoAway(t *testing.T) {
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	testServer.Config.IdleTimeout = 100 * time.Millisecond
+	testServer.EnableHTTP2 = true
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	goAway := make(chan http2.ErrCode, 1)
+
+	trace := &tls_client.ClientTrace{
+		H2GoAwayReceived: func(lastStreamId uint32, code http2.ErrCode, debugData []byte) {
+			goAway <- code
+		},
+	}
+
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), tls_client.WithClientProfile(tls_client.Chrome_107), tls_client.WithInsecureSkipVerify(), tls_client.WithClientTrace(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(testServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	select {
+	case code := <-goAway:
+		assert.Equal(t, http2.ErrCodeNo, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no GOAWAY frame traced")
+	}
+}
+
+// traceRecorder records the names of the hooks which ran and some of their arguments.
+type traceRecorder struct {
+	mu             sync.Mutex
+	events         []string
+	spec           *tls.ClientHelloSpec
+	state          tls.ConnectionState
+	serverSettings []http2.Setting
+	proxyUrl       string
+	reused         bool
+}
+
+func (r *traceRecorder) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, name)
+}
+
+func (r *traceRecorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.events...)
+}
+
+func (r *traceRecorder) trace() *tls_client.ClientTrace {
+	return &tls_client.ClientTrace{
+		DNSStart: func(host string) {
+			r.record("DNSStart")
+		},
+		DNSDone: func(addrs []net.IPAddr, err error) {
+			r.record("DNSDone")
+		},
+		ConnectStart: func(network, addr string) {
+			r.record("ConnectStart")
+		},
+		ConnectDone: func(network, addr string, err error) {
+			r.record("ConnectDone")
+		},
+		ProxyConnectStart: func(proxyUrl, addr string) {
+			r.record("ProxyConnectStart")
+		},
+		ProxyConnectDone: func(proxyUrl, addr string, err error) {
+			r.mu.Lock()
+			r.proxyUrl = proxyUrl
+			r.mu.Unlock()
+
+			r.record("ProxyConnectDone")
+		},
+		TLSHandshakeStart: func(serverName string, spec *tls.ClientHelloSpec) {
+			r.mu.Lock()
+			r.spec = spec
+			r.mu.Unlock()
+
+			r.record("TLSHandshakeStart")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			r.mu.Lock()
+			r.state = state
+			r.mu.Unlock()
+
+			r.record("TLSHandshakeDone")
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			r.reused = info.Reused
+			r.mu.Unlock()
+
+			r.record("GotConn")
+		},
+		GotFirstResponseByte: func() {
+			r.record("GotFirstResponseByte")
+		},
+		H2SettingsReceived: func(settings []http2.Setting) {
+			r.mu.Lock()
+			if r.serverSettings == nil {
+				r.serverSettings = settings
+			}
+			r.mu.Unlock()
+
+			r.record("H2SettingsReceived")
+		},
+	}
+}
+
+// without removes the events starting with one of the prefixes.
+func without(events []string, prefixes ...string) []string {
+	var filtered []string
+
+outer:
+	for _, event := range events {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(event, prefix) {
+				continue outer
+			}
+		}
+
+		filtered = append(filtered, event)
+	}
+
+	return filtered
+}
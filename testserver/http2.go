@@ -0,0 +1,320 @@
+package testserver
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Digman/tls-client/shared"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/bogdanfinn/fhttp/http2/hpack"
+)
+
+var frameFlagNames = map[http2.FrameType][]struct {
+	flag http2.Flags
+	name string
+}{
+	http2.FrameData:         {{http2.FlagDataEndStream, "EndStream (0x1)"}, {http2.FlagDataPadded, "Padded (0x8)"}},
+	http2.FrameHeaders:      {{http2.FlagHeadersEndStream, "EndStream (0x1)"}, {http2.FlagHeadersEndHeaders, "EndHeaders (0x4)"}, {http2.FlagHeadersPadded, "Padded (0x8)"}, {http2.FlagHeadersPriority, "Priority (0x20)"}},
+	http2.FrameSettings:     {{http2.FlagSettingsAck, "Ack (0x1)"}},
+	http2.FramePing:         {{http2.FlagPingAck, "Ack (0x1)"}},
+	http2.FrameContinuation: {{http2.FlagContinuationEndHeaders, "EndHeaders (0x4)"}},
+}
+
+// h2Connection holds what the client sent on an http2 connection so far.
+type h2Connection struct {
+	framer  *http2.Framer
+	decoder *hpack.Decoder
+
+	frames []shared.TlsApiFrame
+
+	// settings, connectionFlow, priorities and pseudoHeaderOrder are what the akamai fingerprint is made of
+	sawSettings       bool
+	settings          []http2.Setting
+	connectionFlow    uint32
+	priorities        []http2.Priority
+	pseudoHeaderOrder []string
+	sawHeaders        bool
+
+	// headerBlock collects the fragments of the header block of headerStream, headerFrame is the index of its HEADERS frame
+	headerBlock       []byte
+	headerStream      uint32
+	headerStreamEnded bool
+	headerFrame       int
+	methods           map[uint32]string
+}
+
+func serveHttp2(conn net.Conn, response shared.TlsApiResponse) error {
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(conn, preface); err != nil {
+		return err
+	}
+
+	if string(preface) != http2.ClientPreface {
+		return errors.New("invalid http2 preface")
+	}
+
+	c := &h2Connection{
+		framer:  http2.NewFramer(conn, conn),
+		decoder: hpack.NewDecoder(4096, nil),
+		methods: make(map[uint32]string),
+	}
+
+	if err := c.framer.WriteSettings(); err != nil {
+		return err
+	}
+
+	for {
+		frame, err := c.framer.ReadFrame()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		endStream, err := c.record(frame)
+		if err != nil {
+			return err
+		}
+
+		if endStream == 0 {
+			continue
+		}
+
+		if err := c.respond(endStream, response); err != nil {
+			return err
+		}
+
+		// every connection answers one request, so the sent frames of every response start with the connection preface
+		if err := c.framer.WriteGoAway(endStream, http2.ErrCodeNo, nil); err != nil {
+			return err
+		}
+
+		drain(conn)
+
+		return nil
+	}
+}
+
+// record adds the frame to the sent frames and answers frames which need an answer.
+// It returns the id of the stream whose request is complete with the frame, 0 if there is none.
+func (c *h2Connection) record(frame http2.Frame) (uint32, error) {
+	header := frame.Header()
+	sent := shared.TlsApiFrame{
+		FrameType: header.Type.String(),
+		Length:    int(header.Length),
+		StreamID:  int(header.StreamID),
+	}
+
+	for _, flag := range frameFlagNames[header.Type] {
+		if header.Flags.Has(flag.flag) {
+			sent.Flags = append(sent.Flags, flag.name)
+		}
+	}
+
+	var endStream uint32
+
+	switch f := frame.(type) {
+	case *http2.SettingsFrame:
+		if f.IsAck() {
+			break
+		}
+
+		first := !c.sawSettings
+		c.sawSettings = true
+
+		_ = f.ForeachSetting(func(setting http2.Setting) error {
+			sent.Settings = append(sent.Settings, fmt.Sprintf("%s = %d", setting.ID, setting.Val))
+
+			if first {
+				c.settings = append(c.settings, setting)
+			}
+
+			return nil
+		})
+
+		if err := c.framer.WriteSettingsAck(); err != nil {
+			return 0, err
+		}
+	case *http2.WindowUpdateFrame:
+		sent.Increment = int(f.Increment)
+
+		if f.StreamID == 0 && c.connectionFlow == 0 {
+			c.connectionFlow = f.Increment
+		}
+	case *http2.PriorityFrame:
+		sent.Priority = priority(f.PriorityParam)
+
+		if !c.sawHeaders {
+			c.priorities = append(c.priorities, http2.Priority{StreamID: f.StreamID, PriorityParam: f.PriorityParam})
+		}
+	case *http2.PingFrame:
+		if !f.IsAck() {
+			if err := c.framer.WritePing(true, f.Data); err != nil {
+				return 0, err
+			}
+		}
+	case *http2.HeadersFrame:
+		if f.HasPriority() {
+			sent.Priority = priority(f.Priority)
+		}
+
+		c.headerBlock = append([]byte(nil), f.HeaderBlockFragment()...)
+		c.headerStream, c.headerStreamEnded = f.StreamID, f.StreamEnded()
+		c.headerFrame = len(c.frames)
+		c.frames = append(c.frames, sent)
+
+		return c.endHeaders(f.HeadersEnded())
+	case *http2.ContinuationFrame:
+		c.headerBlock = append(c.headerBlock, f.HeaderBlockFragment()...)
+		c.frames = append(c.frames, sent)
+
+		return c.endHeaders(f.HeadersEnded())
+	case *http2.DataFrame:
+		if length := uint32(len(f.Data())); length > 0 {
+			if err := c.framer.WriteWindowUpdate(0, length); err != nil {
+				return 0, err
+			}
+
+			if !f.StreamEnded() {
+				if err := c.framer.WriteWindowUpdate(f.StreamID, length); err != nil {
+					return 0, err
+				}
+			}
+		}
+
+		if f.StreamEnded() {
+			endStream = f.StreamID
+		}
+	}
+
+	c.frames = append(c.frames, sent)
+
+	return endStream, nil
+}
+
+// endHeaders decodes the header block once it is complete and returns the stream id if the request has no body.
+func (c *h2Connection) endHeaders(headersEnded bool) (uint32, error) {
+	if !headersEnded {
+		return 0, nil
+	}
+
+	fields, err := c.decoder.DecodeFull(c.headerBlock)
+	if err != nil {
+		return 0, err
+	}
+
+	frame := &c.frames[c.headerFrame]
+	for _, field := range fields {
+		frame.Headers = append(frame.Headers, field.Name+": "+field.Value)
+
+		if field.Name == ":method" {
+			c.methods[c.headerStream] = field.Value
+		}
+
+		if field.IsPseudo() && !c.sawHeaders {
+			c.pseudoHeaderOrder = append(c.pseudoHeaderOrder, field.Name)
+		}
+	}
+
+	c.sawHeaders = true
+
+	if c.headerStreamEnded {
+		return c.headerStream, nil
+	}
+
+	return 0, nil
+}
+
+// respond answers the request of the stream with the fingerprint of the connection.
+func (c *h2Connection) respond(streamId uint32, response shared.TlsApiResponse) error {
+	akamaiFingerprint := c.akamaiFingerprint()
+	akamaiFingerprintHash := md5.Sum([]byte(akamaiFingerprint))
+
+	response.HTTPVersion = "h2"
+	response.Method = c.methods[streamId]
+	response.HTTP2 = shared.TlsApiHTTP2{
+		AkamaiFingerprint:     akamaiFingerprint,
+		AkamaiFingerprintHash: hex.EncodeToString(akamaiFingerprintHash[:]),
+		SentFrames:            append([]shared.TlsApiFrame(nil), c.frames...),
+	}
+
+	delete(c.methods, streamId)
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	var headerBlock bytes.Buffer
+	encoder := hpack.NewEncoder(&headerBlock)
+	_ = encoder.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+	_ = encoder.WriteField(hpack.HeaderField{Name: "content-type", Value: "application/json"})
+	_ = encoder.WriteField(hpack.HeaderField{Name: "content-length", Value: strconv.Itoa(len(body))})
+
+	if err := c.framer.WriteHeaders(http2.HeadersFrameParam{StreamID: streamId, BlockFragment: headerBlock.Bytes(), EndHeaders: true}); err != nil {
+		return err
+	}
+
+	// the client allows at least 16384 bytes per frame
+	for len(body) > 16384 {
+		if err := c.framer.WriteData(streamId, false, body[:16384]); err != nil {
+			return err
+		}
+
+		body = body[16384:]
+	}
+
+	return c.framer.WriteData(streamId, true, body)
+}
+
+// akamaiFingerprint builds the akamai fingerprint from the first SETTINGS and WINDOW_UPDATE frame of the connection,
+// the PRIORITY frames before the first HEADERS frame and the pseudo headers of the first request.
+func (c *h2Connection) akamaiFingerprint() string {
+	var settingParts []string
+	for _, setting := range c.settings {
+		settingParts = append(settingParts, fmt.Sprintf("%d:%d", setting.ID, setting.Val))
+	}
+
+	priorityParts := []string{"0"}
+	if len(c.priorities) > 0 {
+		priorityParts = nil
+	}
+
+	for _, p := range c.priorities {
+		sent := priority(p.PriorityParam)
+		priorityParts = append(priorityParts, fmt.Sprintf("%d:%d:%d:%d", p.StreamID, sent.Exclusive, sent.DependsOn, sent.Weight))
+	}
+
+	var pseudoHeaderParts []string
+	for _, pseudoHeader := range c.pseudoHeaderOrder {
+		if name := strings.TrimPrefix(pseudoHeader, ":"); name != "" {
+			pseudoHeaderParts = append(pseudoHeaderParts, name[:1])
+		}
+	}
+
+	return strings.Join([]string{
+		strings.Join(settingParts, ","),
+		strconv.FormatUint(uint64(c.connectionFlow), 10),
+		strings.Join(priorityParts, ","),
+		strings.Join(pseudoHeaderParts, ","),
+	}, "|")
+}
+
+func priority(param http2.PriorityParam) shared.TlsApiPriority {
+	exclusive := 0
+	if param.Exclusive {
+		exclusive = 1
+	}
+
+	return shared.TlsApiPriority{Weight: int(param.Weight) + 1, DependsOn: int(param.StreamDep), Exclusive: exclusive}
+}
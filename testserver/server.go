@@ -0,0 +1,243 @@
+// Package testserver provides a local https server which answers every request with the fingerprint of the client,
+// in the json format of https://tls.peet.ws/api/all (shared.TlsApiResponse), so fingerprints can be tested without network.
+package testserver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Digman/tls-client/shared"
+	http "github.com/bogdanfinn/fhttp"
+	tls "github.com/bogdanfinn/utls"
+)
+
+// Server terminates tls on a local port with a self signed certificate, clients have to skip the certificate verification.
+type Server struct {
+	listener net.Listener
+	config   *tls.Config
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// NewServer starts a server on a random port of 127.0.0.1.
+func NewServer() (*Server, error) {
+	certificate, err := newCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		config: &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			NextProtos:   []string{"h2", "http/1.1"},
+		},
+		conns: make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// URL returns the url of the server. It uses the host name localhost, so clients send the server name extension like they do for other hosts.
+func (s *Server) URL() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+
+	return "https://localhost:" + port
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes all open connections.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+
+				_ = conn.Close()
+			}()
+
+			_ = s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) error {
+	recorder := &recordingConn{Conn: conn}
+	tlsConn := tls.Server(recorder, s.config)
+
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+
+	state := tlsConn.ConnectionState()
+	recorder.stop()
+
+	tlsInfo, err := parseTLS(recorder.recorded(), state.Version)
+	if err != nil {
+		return err
+	}
+
+	response := shared.TlsApiResponse{
+		IP:  conn.RemoteAddr().String(),
+		TLS: tlsInfo,
+	}
+
+	if state.NegotiatedProtocol == "h2" {
+		return serveHttp2(tlsConn, response)
+	}
+
+	return serveHttp1(tlsConn, response)
+}
+
+func serveHttp1(conn net.Conn, response shared.TlsApiResponse) error {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return err
+	}
+
+	_, _ = io.Copy(io.Discard, req.Body)
+	_ = req.Body.Close()
+
+	response.HTTPVersion = req.Proto
+	response.Method = req.Method
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	var header bytes.Buffer
+	header.WriteString("HTTP/1.1 200 OK\r\n")
+	header.WriteString("Content-Type: application/json\r\n")
+	header.WriteString("Content-Length: " + strconv.Itoa(len(body)) + "\r\n")
+	header.WriteString("Connection: close\r\n\r\n")
+
+	if _, err := conn.Write(append(header.Bytes(), body...)); err != nil {
+		return err
+	}
+
+	drain(conn)
+
+	return nil
+}
+
+// drain reads until the client closes the connection, closing it earlier could reset it before the client read the response.
+func drain(conn net.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = io.Copy(io.Discard, conn)
+}
+
+// recordingConn records what the client sends until stop is called.
+type recordingConn struct {
+	net.Conn
+
+	mu      sync.Mutex
+	data    []byte
+	stopped bool
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+
+	c.mu.Lock()
+	if !c.stopped {
+		c.data = append(c.data, p[:n]...)
+	}
+	c.mu.Unlock()
+
+	return n, err
+}
+
+func (c *recordingConn) stop() {
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+}
+
+func (c *recordingConn) recorded() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.data
+}
+
+func newCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
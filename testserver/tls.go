@@ -0,0 +1,359 @@
+package testserver
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Digman/tls-client/shared"
+	tls "github.com/bogdanfinn/utls"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+var extensionNames = map[uint16]string{
+	0:     "server_name",
+	5:     "status_request",
+	10:    "supported_groups",
+	11:    "ec_point_formats",
+	13:    "signature_algorithms",
+	16:    "application_layer_protocol_negotiation",
+	17:    "status_request_v2",
+	18:    "signed_certificate_timestamp",
+	21:    "padding",
+	22:    "encrypt_then_mac",
+	23:    "extended_master_secret",
+	24:    "token_binding",
+	27:    "compress_certificate",
+	28:    "record_size_limit",
+	34:    "delegated_credentials",
+	35:    "session_ticket",
+	41:    "pre_shared_key",
+	42:    "early_data",
+	43:    "supported_versions",
+	44:    "cookie",
+	45:    "psk_key_exchange_modes",
+	49:    "post_handshake_auth",
+	50:    "signature_algorithms_cert",
+	51:    "key_share",
+	13172: "next_protocol_negotiation",
+	17513: "application_settings",
+	30031: "channel_id_old",
+	30032: "channel_id",
+	65281: "renegotiation_info",
+}
+
+var certCompressionNames = map[uint16]string{
+	1: "zlib",
+	2: "brotli",
+	3: "zstd",
+}
+
+var pskModeNames = map[uint8]string{
+	0: "PSK-only key establishment (psk)",
+	1: "PSK with (EC)DHE key establishment (psk_dhe_ke)",
+}
+
+// parseTLS returns the tls part of the response for the data the client sent during the handshake.
+func parseTLS(data []byte, negotiatedVersion uint16) (shared.TlsApiTLS, error) {
+	if len(data) < 5 || data[0] != 22 {
+		return shared.TlsApiTLS{}, errors.New("connection does not start with a handshake record")
+	}
+
+	recordVersion := binary.BigEndian.Uint16(data[1:3])
+
+	var message []byte
+	for len(data) >= 5 && data[0] == 22 {
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+length {
+			break
+		}
+
+		message = append(message, data[5:5+length]...)
+		data = data[5+length:]
+
+		if len(message) >= 4 && len(message) >= 4+(int(message[1])<<16|int(message[2])<<8|int(message[3])) {
+			break
+		}
+	}
+
+	info := shared.TlsApiTLS{
+		TLSVersionRecord:     strconv.Itoa(int(recordVersion)),
+		TLSVersionNegotiated: strconv.Itoa(int(negotiatedVersion)),
+	}
+
+	s := cryptobyte.String(message)
+
+	var helloVersion uint16
+	var random, sessionId, cipherSuites, compressionMethods, extensions cryptobyte.String
+	if !s.Skip(4) || !s.ReadUint16(&helloVersion) || !s.ReadBytes((*[]byte)(&random), 32) || !s.ReadUint8LengthPrefixed(&sessionId) ||
+		!s.ReadUint16LengthPrefixed(&cipherSuites) || !s.ReadUint8LengthPrefixed(&compressionMethods) {
+		return shared.TlsApiTLS{}, errors.New("invalid ClientHello")
+	}
+
+	fingerprint := ja3Fields{version: helloVersion}
+
+	info.ClientRandom = hex.EncodeToString(random)
+	info.SessionID = hex.EncodeToString(sessionId)
+
+	for !cipherSuites.Empty() {
+		var suite uint16
+		if !cipherSuites.ReadUint16(&suite) {
+			return shared.TlsApiTLS{}, errors.New("invalid cipher suites")
+		}
+
+		info.Ciphers = append(info.Ciphers, cipherSuiteName(suite))
+		fingerprint.addCipherSuite(suite)
+	}
+
+	// the extensions are optional
+	if s.ReadUint16LengthPrefixed(&extensions) {
+		for !extensions.Empty() {
+			var id uint16
+			var extensionData cryptobyte.String
+			if !extensions.ReadUint16(&id) || !extensions.ReadUint16LengthPrefixed(&extensionData) {
+				return shared.TlsApiTLS{}, errors.New("invalid extensions")
+			}
+
+			info.Extensions = append(info.Extensions, parseExtension(id, extensionData))
+			fingerprint.addExtension(id, extensionData)
+		}
+	}
+
+	info.Ja3 = fingerprint.String()
+	ja3Hash := md5.Sum([]byte(info.Ja3))
+	info.Ja3Hash = hex.EncodeToString(ja3Hash[:])
+
+	return info, nil
+}
+
+// ja3Fields collects the fields of the ClientHello which make up its JA3 string, GREASE values are left out.
+type ja3Fields struct {
+	version      uint16
+	cipherSuites []string
+	extensions   []string
+	curves       []string
+	pointFormats []string
+}
+
+func (f *ja3Fields) addCipherSuite(suite uint16) {
+	if !isGREASE(suite) {
+		f.cipherSuites = append(f.cipherSuites, strconv.Itoa(int(suite)))
+	}
+}
+
+func (f *ja3Fields) addExtension(id uint16, data cryptobyte.String) {
+	if isGREASE(id) {
+		return
+	}
+
+	f.extensions = append(f.extensions, strconv.Itoa(int(id)))
+
+	switch id {
+	case 10:
+		curves, _ := readUint16List(&data)
+		for _, curve := range curves {
+			if !isGREASE(curve) {
+				f.curves = append(f.curves, strconv.Itoa(int(curve)))
+			}
+		}
+	case 11:
+		var formats cryptobyte.String
+		if data.ReadUint8LengthPrefixed(&formats) {
+			for _, format := range formats {
+				f.pointFormats = append(f.pointFormats, strconv.Itoa(int(format)))
+			}
+		}
+	}
+}
+
+func (f *ja3Fields) String() string {
+	return strings.Join([]string{
+		strconv.Itoa(int(f.version)),
+		strings.Join(f.cipherSuites, "-"),
+		strings.Join(f.extensions, "-"),
+		strings.Join(f.curves, "-"),
+		strings.Join(f.pointFormats, "-"),
+	}, ",")
+}
+
+// parseExtension returns the extension with its parsed fields, the data of extensions without them is hex encoded.
+func parseExtension(id uint16, data cryptobyte.String) shared.TlsApiExtension {
+	extension := shared.TlsApiExtension{Name: extensionName(id)}
+	raw := []byte(data)
+
+	parsed := false
+
+	switch id {
+	case 0:
+		var names, name cryptobyte.String
+		var nameType uint8
+		if data.ReadUint16LengthPrefixed(&names) && names.ReadUint8(&nameType) && names.ReadUint16LengthPrefixed(&name) {
+			extension.ServerName, parsed = string(name), true
+		}
+	case 10:
+		if groups, ok := readUint16List(&data); ok {
+			for _, group := range groups {
+				extension.SupportedGroups = append(extension.SupportedGroups, curveName(group))
+			}
+
+			parsed = true
+		}
+	case 11:
+		var formats cryptobyte.String
+		if data.ReadUint8LengthPrefixed(&formats) {
+			var pointFormats []string
+			for _, format := range formats {
+				pointFormats = append(pointFormats, fmt.Sprintf("0x%02x", format))
+			}
+
+			extension.EllipticCurvesPointFormats, parsed = pointFormats, true
+		}
+	case 13, 50:
+		if algorithms, ok := readUint16List(&data); ok {
+			for _, algorithm := range algorithms {
+				extension.SignatureAlgorithms = append(extension.SignatureAlgorithms, tls.SignatureScheme(algorithm).String())
+			}
+
+			parsed = true
+		}
+	case 16, 17513:
+		var protocols cryptobyte.String
+		if data.ReadUint16LengthPrefixed(&protocols) {
+			for !protocols.Empty() {
+				var protocol cryptobyte.String
+				if !protocols.ReadUint8LengthPrefixed(&protocol) {
+					break
+				}
+
+				extension.Protocols = append(extension.Protocols, string(protocol))
+			}
+
+			parsed = true
+		}
+	case 21:
+		extension.PaddingDataLength, parsed = len(raw), true
+	case 27:
+		var algorithms cryptobyte.String
+		if data.ReadUint8LengthPrefixed(&algorithms) {
+			for !algorithms.Empty() {
+				var algorithm uint16
+				if !algorithms.ReadUint16(&algorithm) {
+					break
+				}
+
+				extension.Algorithms = append(extension.Algorithms, fmt.Sprintf("%s (%d)", nameOr(certCompressionNames[algorithm], "unknown"), algorithm))
+			}
+
+			parsed = true
+		}
+	case 43:
+		var versions cryptobyte.String
+		if data.ReadUint8LengthPrefixed(&versions) {
+			for !versions.Empty() {
+				var version uint16
+				if !versions.ReadUint16(&version) {
+					break
+				}
+
+				extension.Versions = append(extension.Versions, versionName(version))
+			}
+
+			parsed = true
+		}
+	case 45:
+		var modes cryptobyte.String
+		if data.ReadUint8LengthPrefixed(&modes) && len(modes) > 0 {
+			extension.PskKeyExchangeMode, parsed = nameOr(pskModeNames[modes[0]], strconv.Itoa(int(modes[0]))), true
+		}
+	case 5:
+		var statusType uint8
+		var responderIds, requestExtensions cryptobyte.String
+		if data.ReadUint8(&statusType) && data.ReadUint16LengthPrefixed(&responderIds) && data.ReadUint16LengthPrefixed(&requestExtensions) {
+			extension.StatusRequest = shared.TlsApiStatusRequest{
+				CertificateStatusType:   fmt.Sprintf("OSCP (%d)", statusType),
+				ResponderIDListLength:   len(responderIds),
+				RequestExtensionsLength: len(requestExtensions),
+			}
+			parsed = true
+		}
+	}
+
+	if !parsed && len(raw) > 0 {
+		extension.Data = hex.EncodeToString(raw)
+	}
+
+	return extension
+}
+
+func readUint16List(data *cryptobyte.String) ([]uint16, bool) {
+	var list cryptobyte.String
+	if !data.ReadUint16LengthPrefixed(&list) || len(list)%2 != 0 {
+		return nil, false
+	}
+
+	var values []uint16
+	for !list.Empty() {
+		var value uint16
+		list.ReadUint16(&value)
+		values = append(values, value)
+	}
+
+	return values, true
+}
+
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+func extensionName(id uint16) string {
+	if isGREASE(id) {
+		return fmt.Sprintf("TLS_GREASE (0x%x)", id)
+	}
+
+	return fmt.Sprintf("%s (%d)", nameOr(extensionNames[id], "unknown"), id)
+}
+
+func cipherSuiteName(id uint16) string {
+	if isGREASE(id) {
+		return fmt.Sprintf("TLS_GREASE (0x%X)", id)
+	}
+
+	return tls.CipherSuiteName(id)
+}
+
+func curveName(id uint16) string {
+	if isGREASE(id) {
+		return fmt.Sprintf("TLS_GREASE (0x%x)", id)
+	}
+
+	return fmt.Sprintf("%s (%d)", tls.CurveID(id).String(), id)
+}
+
+func versionName(version uint16) string {
+	switch {
+	case isGREASE(version):
+		return fmt.Sprintf("TLS_GREASE (0x%x)", version)
+	case version == tls.VersionTLS13:
+		return "TLS 1.3"
+	case version == tls.VersionTLS12:
+		return "TLS 1.2"
+	case version == tls.VersionTLS11:
+		return "TLS 1.1"
+	case version == tls.VersionTLS10:
+		return "TLS 1.0"
+	default:
+		return strconv.Itoa(int(version))
+	}
+}
+
+func nameOr(name string, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+
+	return name
+}
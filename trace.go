@@ -0,0 +1,147 @@
+package tls_client
+
+import (
+	"context"
+	"net"
+
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/http2"
+	"github.com/bogdanfinn/fhttp/httptrace"
+	utls "github.com/bogdanfinn/utls"
+)
+
+// ClientTrace is a set of hooks which run at the stages of a request, every hook is optional.
+// Like with net/http/httptrace the dial and handshake hooks only run for the request which opens a connection,
+// connections opened by the http2 transport on its own use the trace of the client.
+type ClientTrace struct {
+	// DNSStart and DNSDone run around the lookup of the destination host of direct connections, not for ip addresses.
+	DNSStart func(host string)
+	DNSDone  func(addrs []net.IPAddr, err error)
+	// ConnectStart and ConnectDone run around every tcp connect to the destination or to the proxy,
+	// direct connections report every address of the host they try, e.g. an ipv6 and an ipv4 one.
+	ConnectStart func(network, addr string)
+	ConnectDone  func(network, addr string, err error)
+	// ProxyConnectStart and ProxyConnectDone run around opening a tunnel through an http or https proxy, including the dial of the proxy.
+	// The proxyUrl has no password.
+	ProxyConnectStart func(proxyUrl, addr string)
+	ProxyConnectDone  func(proxyUrl, addr string, err error)
+	// TLSHandshakeStart gets the client hello which is sent, nil if it could not be built before the handshake.
+	TLSHandshakeStart func(serverName string, spec *utls.ClientHelloSpec)
+	TLSHandshakeDone  func(state utls.ConnectionState, err error)
+	GotConn           func(info httptrace.GotConnInfo)
+	// GotFirstResponseByte runs when the first byte of the response headers is available.
+	GotFirstResponseByte func()
+	// H2SettingsReceived and H2GoAwayReceived run for the frames received on http2 connections, except ACKs.
+	H2SettingsReceived func(settings []http2.Setting)
+	H2GoAwayReceived   func(lastStreamId uint32, code http2.ErrCode, debugData []byte)
+}
+
+// ContextKeyClientTrace is the context key of a *ClientTrace used for one request instead of the one of the client.
+type ContextKeyClientTrace struct{}
+
+func clientTraceFrom(ctx context.Context) *ClientTrace {
+	trace, _ := ctx.Value(ContextKeyClientTrace{}).(*ClientTrace)
+
+	return trace
+}
+
+// withClientTrace makes the dialers and transports see the trace of the request, or the one of the client if it has none.
+func withClientTrace(req *http.Request, clientTrace *ClientTrace) *http.Request {
+	ctx := req.Context()
+
+	trace := clientTraceFrom(ctx)
+	if trace == nil {
+		if clientTrace == nil {
+			return req
+		}
+
+		trace = clientTrace
+		ctx = context.WithValue(ctx, ContextKeyClientTrace{}, trace)
+	}
+
+	if trace.GotConn != nil || trace.GotFirstResponseByte != nil {
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GotConn:              trace.GotConn,
+			GotFirstResponseByte: trace.GotFirstResponseByte,
+		})
+	}
+
+	return req.WithContext(ctx)
+}
+
+func (t *ClientTrace) dnsStart(host string) {
+	if t != nil && t.DNSStart != nil {
+		t.DNSStart(host)
+	}
+}
+
+func (t *ClientTrace) dnsDone(addrs []net.IPAddr, err error) {
+	if t != nil && t.DNSDone != nil {
+		t.DNSDone(addrs, err)
+	}
+}
+
+func (t *ClientTrace) connectStart(network, addr string) {
+	if t != nil && t.ConnectStart != nil {
+		t.ConnectStart(network, addr)
+	}
+}
+
+func (t *ClientTrace) connectDone(network, addr string, err error) {
+	if t != nil && t.ConnectDone != nil {
+		t.ConnectDone(network, addr, err)
+	}
+}
+
+func (t *ClientTrace) proxyConnectStart(proxyUrl, addr string) {
+	if t != nil && t.ProxyConnectStart != nil {
+		t.ProxyConnectStart(proxyUrl, addr)
+	}
+}
+
+func (t *ClientTrace) proxyConnectDone(proxyUrl, addr string, err error) {
+	if t != nil && t.ProxyConnectDone != nil {
+		t.ProxyConnectDone(proxyUrl, addr, err)
+	}
+}
+
+func (t *ClientTrace) tlsHandshakeStart(serverName string, conn *utls.UConn) {
+	if t == nil || t.TLSHandshakeStart == nil {
+		return
+	}
+
+	// building the handshake state early applies the spec, the handshake later on reuses it
+	var spec *utls.ClientHelloSpec
+	if err := conn.BuildHandshakeState(); err == nil {
+		spec = &utls.ClientHelloSpec{
+			CipherSuites:       conn.HandshakeState.Hello.CipherSuites,
+			CompressionMethods: conn.HandshakeState.Hello.CompressionMethods,
+			Extensions:         conn.Extensions,
+		}
+	}
+
+	t.TLSHandshakeStart(serverName, spec)
+}
+
+func (t *ClientTrace) tlsHandshakeDone(state utls.ConnectionState, err error) {
+	if t != nil && t.TLSHandshakeDone != nil {
+		t.TLSHandshakeDone(state, err)
+	}
+}
+
+func (t *ClientTrace) h2SettingsReceived(settings []http2.Setting) {
+	if t != nil && t.H2SettingsReceived != nil {
+		t.H2SettingsReceived(settings)
+	}
+}
+
+func (t *ClientTrace) h2GoAwayReceived(lastStreamId uint32, code http2.ErrCode, debugData []byte) {
+	if t != nil && t.H2GoAwayReceived != nil {
+		t.H2GoAwayReceived(lastStreamId, code, debugData)
+	}
+}
+
+// tracesH2Frames reports whether frames after the first SETTINGS frame have to be parsed.
+func (t *ClientTrace) tracesH2Frames() bool {
+	return t != nil && (t.H2SettingsReceived != nil || t.H2GoAwayReceived != nil)
+}